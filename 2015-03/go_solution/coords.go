@@ -0,0 +1,82 @@
+package main
+
+// CoordSystem is a grid with its moves
+type CoordSystem interface {
+	// Delta returns the move of a single symbol
+	Delta(direction rune) (Point, error)
+	// Directions returns the direction table of the grid for InstructionReader
+	Directions() Directions
+	// Distance returns the least number of moves between the points
+	Distance(a, b Point) int
+}
+
+// Square is the grid of the puzzle with the moves <>^v
+type Square struct{}
+
+func (Square) Delta(direction rune) (Point, error) {
+	return shift(Point{}, direction)
+}
+
+func (Square) Directions() Directions {
+	return ArrowDirections
+}
+
+// Distance is the Manhattan distance
+func (Square) Distance(a, b Point) int {
+	return abs(a.x-b.x) + abs(a.y-b.y)
+}
+
+// Hex is a grid of hexagons in axial coordinates (q, r) stored in x and y.
+// Hexagons are pointy-topped and r grows to the south-east, the moves are the keys around s:
+// w (north-west), e (north-east), a (west), d (east), z (south-west) and x (south-east).
+type Hex struct{}
+
+var hexDirections = Directions{
+	"w": {0, -1, 0}, "e": {1, -1, 0},
+	"a": {-1, 0, 0}, "d": {1, 0, 0},
+	"z": {-1, 1, 0}, "x": {0, 1, 0},
+}
+
+func (Hex) Delta(direction rune) (Point, error) {
+	return tableDelta(hexDirections, direction)
+}
+
+func (Hex) Directions() Directions {
+	return hexDirections
+}
+
+func (Hex) Distance(a, b Point) int {
+	dq, dr := a.x-b.x, a.y-b.y
+	return (abs(dq) + abs(dr) + abs(dq+dr)) / 2
+}
+
+// Cube is the square grid with floors: the moves <>^v and u (up), d (down)
+type Cube struct{}
+
+var cubeDirections = Directions{
+	"<": {-1, 0, 0}, ">": {1, 0, 0}, "^": {0, 1, 0}, "v": {0, -1, 0},
+	"u": {0, 0, 1}, "d": {0, 0, -1},
+}
+
+func (Cube) Delta(direction rune) (Point, error) {
+	return tableDelta(cubeDirections, direction)
+}
+
+func (Cube) Directions() Directions {
+	return cubeDirections
+}
+
+// Distance is the Manhattan distance
+func (Cube) Distance(a, b Point) int {
+	return abs(a.x-b.x) + abs(a.y-b.y) + abs(a.z-b.z)
+}
+
+// tableDelta returns the move of a single symbol of the direction table,
+// so Delta and Directions of a grid cannot disagree
+func tableDelta(dirs Directions, direction rune) (Point, error) {
+	delta, ok := dirs[string(direction)]
+	if !ok {
+		return Point{}, &SymbolError{direction, -1}
+	}
+	return delta, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Directions maps symbols of instructions to moves on the grid
+type Directions map[string]Point
+
+var (
+	// ArrowDirections is the alphabet of the puzzle
+	ArrowDirections = Directions{"<": {-1, 0, 0}, ">": {1, 0, 0}, "^": {0, 1, 0}, "v": {0, -1, 0}}
+	// DiagonalDirections adds diagonals to arrows as the keys around s on the keyboard
+	DiagonalDirections = Directions{
+		"<": {-1, 0, 0}, ">": {1, 0, 0}, "^": {0, 1, 0}, "v": {0, -1, 0},
+		"q": {-1, 1, 0}, "e": {1, 1, 0}, "z": {-1, -1, 0}, "c": {1, -1, 0},
+	}
+	// CompassDirections are compass points, NE is a single move to the north-east
+	CompassDirections = Directions{
+		"N": {0, 1, 0}, "S": {0, -1, 0}, "E": {1, 0, 0}, "W": {-1, 0, 0},
+		"NE": {1, 1, 0}, "NW": {-1, 1, 0}, "SE": {1, -1, 0}, "SW": {-1, -1, 0},
+	}
+
+	DirectionTables = map[string]Directions{
+		"arrows":   ArrowDirections,
+		"diagonal": DiagonalDirections,
+		"compass":  CompassDirections,
+	}
+)
+
+// maxCount limits the run-length count, bigger counts are surely a broken file
+const maxCount = 1 << 30
+
+// Instruction is a move repeated Count times
+type Instruction struct {
+	Symbol string
+	Delta  Point
+	Count  int
+}
+
+// InstructionReader reads instructions like ^12>3: a symbol of the direction table
+// with an optional repeat count. Whitespace between instructions is skipped.
+// The longest symbol matches, so with compass directions NE is a single move.
+type InstructionReader struct {
+	// Lenient skips symbols which are not in the direction table instead of failing
+	Lenient bool
+
+	r       *bufio.Reader
+	dirs    Directions
+	maxLen  int
+	offset  int
+	skipped int
+}
+
+func NewInstructionReader(r io.Reader, dirs Directions) *InstructionReader {
+	maxLen := 0
+	for symbol := range dirs {
+		maxLen = max(maxLen, len(symbol))
+	}
+	return &InstructionReader{r: bufio.NewReader(r), dirs: dirs, maxLen: maxLen}
+}
+
+// Skipped returns the number of symbols skipped in lenient mode
+func (ir *InstructionReader) Skipped() int {
+	return ir.skipped
+}
+
+// Next returns the next instruction or io.EOF at the end of the input
+func (ir *InstructionReader) Next() (Instruction, error) {
+	for {
+		in, err := ir.next()
+		var se *SymbolError
+		if !ir.Lenient || !errors.As(err, &se) {
+			return in, err
+		}
+		_, size, _ := ir.r.ReadRune()
+		ir.offset += size
+		ir.skipped++
+	}
+}
+
+func (ir *InstructionReader) next() (Instruction, error) {
+	err := ir.skipSpaces()
+	if err != nil {
+		return Instruction{}, err
+	}
+
+	head, err := ir.r.Peek(ir.maxLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return Instruction{}, err
+	}
+	var in Instruction
+	found := false
+	for l := len(head); l > 0 && !found; l-- {
+		in.Delta, found = ir.dirs[string(head[:l])]
+		if found {
+			in.Symbol = string(head[:l])
+			ir.discard(l)
+		}
+	}
+	if !found {
+		r, _ := utf8.DecodeRune(head)
+		return Instruction{}, &SymbolError{r, int64(ir.offset)}
+	}
+
+	in.Count, err = ir.readCount()
+	return in, err
+}
+
+func (ir *InstructionReader) skipSpaces() error {
+	for {
+		r, size, err := ir.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if !unicode.IsSpace(r) {
+			return ir.r.UnreadRune()
+		}
+		ir.offset += size
+	}
+}
+
+func (ir *InstructionReader) discard(n int) {
+	ir.r.Discard(n)
+	ir.offset += n
+}
+
+// readCount reads the repeat count, a missing count is 1
+func (ir *InstructionReader) readCount() (int, error) {
+	count, digits := 0, 0
+	for {
+		b, err := ir.r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if b < '0' || b > '9' {
+			ir.r.UnreadByte()
+			break
+		}
+		count = count*10 + int(b-'0')
+		if count > maxCount {
+			return 0, fmt.Errorf("repeat count at offset %d is bigger than %d", ir.offset-digits, maxCount)
+		}
+		digits++
+		ir.offset++
+	}
+	if digits == 0 {
+		return 1, nil
+	}
+	return count, nil
+}
+
+// DeltaMover is a path which moves by deltas of direction tables
+type DeltaMover interface {
+	MoveBy(delta Point) error
+}
+
+// MoveInstructions moves all paths by every instruction of the reader.
+// A repeated instruction is the same as the symbol written count times.
+func MoveInstructions(reader io.Reader, dirs Directions, dm ...DeltaMover) error {
+	return moveInstructions(NewInstructionReader(reader, dirs), dm...)
+}
+
+func moveInstructions(ir *InstructionReader, dm ...DeltaMover) error {
+	for {
+		in, err := ir.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for i := 0; i < in.Count; i++ {
+			for _, m := range dm {
+				err = m.MoveBy(in.Delta)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
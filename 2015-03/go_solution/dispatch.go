@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxAgents is the biggest number of agents of MultiAgentPath
+const MaxAgents = 128
+
+// Dispatcher chooses the agent which makes a move. Steps are counted from 0.
+type Dispatcher interface {
+	Agent(step int) int
+}
+
+// RoundRobin is the number of agents moving one after another: 0, 1, ..., n-1, 0, 1, ...
+type RoundRobin int
+
+func (rr RoundRobin) Agent(step int) int {
+	return step % int(rr)
+}
+
+// Schedule is a list of agents repeated over and over
+type Schedule []int
+
+func (s Schedule) Agent(step int) int {
+	return s[step%len(s)]
+}
+
+// NewWeighted creates a schedule where every agent makes as many moves in a row as its weight.
+// Weights 2, 1 give the schedule 0, 0, 1.
+func NewWeighted(weights ...int) (Schedule, error) {
+	s := make(Schedule, 0, len(weights))
+	for agent, w := range weights {
+		if w < 0 {
+			return nil, fmt.Errorf("weight of agent %d is negative", agent)
+		}
+		for i := 0; i < w; i++ {
+			s = append(s, agent)
+		}
+	}
+	if len(s) == 0 {
+		return nil, fmt.Errorf("all weights are zero")
+	}
+	return s, nil
+}
+
+// ParseSchedule parses a comma separated list of agents like "0,1,1,2" for the given number of agents
+func ParseSchedule(str string, agents int) (Schedule, error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, errors.New("schedule is empty")
+	}
+	fields := strings.Split(str, ",")
+	s := make(Schedule, 0, len(fields))
+	for _, f := range fields {
+		agent, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, fmt.Errorf("invalid agent %q in schedule", f)
+		}
+		if agent < 0 || agent >= agents {
+			return nil, fmt.Errorf("agent %d in schedule is out of %d agents", agent, agents)
+		}
+		s = append(s, agent)
+	}
+	return s, nil
+}
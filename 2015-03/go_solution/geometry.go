@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Trajectory is the ordered points of an agent from the start point on its grid
+type Trajectory struct {
+	Points []Point
+	// Coords measures the distances, Cube by default as it covers square grids as well
+	Coords CoordSystem
+}
+
+// Trajectory returns the points of the agent. The trail must be recorded from the start.
+func (mp *MultiAgentPath) Trajectory(agent int) (Trajectory, error) {
+	if agent < 0 || agent >= len(mp.agents) {
+		return Trajectory{}, fmt.Errorf("agent %d of %d", agent, len(mp.agents))
+	}
+	if !mp.recording || len(mp.trail) != mp.step {
+		return Trajectory{}, errors.New("trail is not recorded from the start")
+	}
+	t := Trajectory{Points: []Point{{}}, Coords: mp.coords}
+	for _, s := range mp.trail {
+		if s.Agent == agent {
+			t.Points = append(t.Points, s.Point)
+		}
+	}
+	return t, nil
+}
+
+func (t Trajectory) coords() CoordSystem {
+	if t.Coords == nil {
+		return Cube{}
+	}
+	return t.Coords
+}
+
+// Bounds returns the smallest box of the trajectory, Max is inclusive.
+// An empty trajectory has zero bounds.
+func (t Trajectory) Bounds() (minP, maxP Point) {
+	if len(t.Points) == 0 {
+		return Point{}, Point{}
+	}
+	minP, maxP = t.Points[0], t.Points[0]
+	for _, p := range t.Points {
+		minP = Point{min(minP.x, p.x), min(minP.y, p.y), min(minP.z, p.z)}
+		maxP = Point{max(maxP.x, p.x), max(maxP.y, p.y), max(maxP.z, p.z)}
+	}
+	return minP, maxP
+}
+
+// Farthest returns the first point with the biggest distance of the grid from the start and the distance,
+// zero Point for an empty trajectory
+func (t Trajectory) Farthest() (Point, int) {
+	if len(t.Points) == 0 {
+		return Point{}, 0
+	}
+	coords := t.coords()
+	farthest, dist := t.Points[0], 0
+	for _, p := range t.Points {
+		d := coords.Distance(t.Points[0], p)
+		if d > dist {
+			farthest, dist = p, d
+		}
+	}
+	return farthest, dist
+}
+
+// FirstRevisit returns the first house visited the second time and the step of the visit,
+// the step of the start point is 0
+func (t Trajectory) FirstRevisit() (Point, int, bool) {
+	seen := make(map[Point]struct{}, len(t.Points))
+	for step, p := range t.Points {
+		if _, ok := seen[p]; ok {
+			return p, step, true
+		}
+		seen[p] = struct{}{}
+	}
+	return Point{}, 0, false
+}
+
+// Intersection is a house the trajectory goes through several times
+type Intersection struct {
+	Point Point
+	// Steps are all the steps of the visits in order
+	Steps []int
+}
+
+// Intersections returns all houses visited more than once ordered by the step of the second visit
+func (t Trajectory) Intersections() []Intersection {
+	steps := make(map[Point][]int, len(t.Points))
+	order := make([]Point, 0)
+	for step, p := range t.Points {
+		steps[p] = append(steps[p], step)
+		if len(steps[p]) == 2 {
+			order = append(order, p)
+		}
+	}
+	intersections := make([]Intersection, 0, len(order))
+	for _, p := range order {
+		intersections = append(intersections, Intersection{p, steps[p]})
+	}
+	return intersections
+}
+
+// edge is a move between two houses in both directions, from is the lesser house
+type edge struct {
+	from, to Point
+}
+
+// DistinctEdges returns the length of the trajectory without moves along the same edge again,
+// in either direction. Every move is a single edge.
+func (t Trajectory) DistinctEdges() int {
+	edges := make(map[edge]struct{}, len(t.Points))
+	for i := 1; i < len(t.Points); i++ {
+		e := edge{t.Points[i-1], t.Points[i]}
+		if comparePoints(e.to, e.from) < 0 {
+			e.from, e.to = e.to, e.from
+		}
+		edges[e] = struct{}{}
+	}
+	return len(edges)
+}
+
+// WriteGeometry writes the answers of all the queries of the trajectory
+func (t Trajectory) WriteGeometry(w io.Writer) error {
+	minP, maxP := t.Bounds()
+	farthest, dist := t.Farthest()
+	_, err := fmt.Fprintf(w, "bounds %s - %s, farthest %s at %d, distinct edges %d of %d moves\n",
+		t.format(minP), t.format(maxP), t.format(farthest), dist, t.DistinctEdges(), max(len(t.Points)-1, 0))
+	if err != nil {
+		return err
+	}
+	p, step, ok := t.FirstRevisit()
+	if !ok {
+		_, err = fmt.Fprintln(w, "no revisits")
+		return err
+	}
+	_, err = fmt.Fprintf(w, "first revisit %s at step %d, self-intersections %d\n",
+		t.format(p), step, len(t.Intersections()))
+	return err
+}
+
+// format writes the point as (x, y), cube grids have floors: (x, y, z)
+func (t Trajectory) format(p Point) string {
+	if _, ok := t.Coords.(Cube); ok {
+		return fmt.Sprintf("(%d, %d, %d)", p.x, p.y, p.z)
+	}
+	return fmt.Sprintf("(%d, %d)", p.x, p.y)
+}
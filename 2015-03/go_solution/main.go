@@ -2,17 +2,50 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"time"
 )
 
+// Point is a house on the grid. Square grids use x and y, hex grids use x and y
+// as axial coordinates q and r and only cube grids use z.
 type Point struct {
-	x, y int
+	x, y, z int
+}
+
+func (p Point) add(delta Point) Point {
+	return Point{p.x + delta.x, p.y + delta.y, p.z + delta.z}
 }
 
 type PointSet = map[Point]struct{}
 
+// SymbolError is an instruction symbol which is not a direction of the grid
+type SymbolError struct {
+	Symbol rune
+	// Offset is the byte offset of the symbol in the input, -1 when it is unknown
+	Offset int64
+}
+
+func (e *SymbolError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("unexpected symbol %q", e.Symbol)
+	}
+	return fmt.Sprintf("unexpected symbol %q at offset %d", e.Symbol, e.Offset)
+}
+
+// atOffset sets the offset of the SymbolError in err if it is unknown
+func atOffset(err error, offset int64) error {
+	var se *SymbolError
+	if errors.As(err, &se) && se.Offset < 0 {
+		se.Offset = offset
+	}
+	return err
+}
+
 func shift(current Point, direction rune) (Point, error) {
 	switch direction {
 	case '<':
@@ -24,75 +57,171 @@ func shift(current Point, direction rune) (Point, error) {
 	case 'v':
 		current.y -= 1
 	default:
-		return current, fmt.Errorf("unexpected symbol %v", direction)
+		return current, &SymbolError{direction, -1}
 	}
 	return current, nil
 }
 
+// innerPath keeps the visited houses in a store
 type innerPath struct {
-	visited PointSet
+	store VisitStore
+}
+
+func newInnerPath(store VisitStore, agents int) innerPath {
+	ip := innerPath{store: store}
+	// every agent delivers a present at the start
+	for a := 0; a < agents; a++ {
+		ip.visit(Point{0, 0, 0}, a, 1)
+	}
+	return ip
+}
+
+func (ip innerPath) visit(p Point, agent, n int) {
+	ip.store.VisitN(p, agent, n)
 }
 
 func (ip innerPath) Len() int {
-	return len(ip.visited)
+	return ip.store.Len()
 }
 
-type SantaPath struct {
-	current Point
+// MultiAgentPath is a path of several agents starting at the same point.
+// Every move is made by the agent chosen by the dispatcher.
+type MultiAgentPath struct {
+	coords   CoordSystem
+	agents   []Point
+	dispatch Dispatcher
+	step     int
+	// trail is recorded only after RecordTrail as it grows with every move,
+	// trailFrom are the agent positions when the recording started
+	trail     []Step
+	trailFrom []Point
+	recording bool
 	innerPath
 }
 
+// Step is a move of the agent into the point
+type Step struct {
+	Agent int
+	Point Point
+}
+
+// SantaPath is a path of Santa alone
+type SantaPath = MultiAgentPath
+
+// SantaRobotPath is a path of Santa and Robo-Santa taking turns
+type SantaRobotPath = MultiAgentPath
+
 func NewSantaPath() *SantaPath {
-	startPoint := Point{0, 0}
-	return &SantaPath{
-		current:   startPoint,
-		innerPath: innerPath{visited: PointSet{startPoint: struct{}{}}},
+	return newMultiAgentPath(Square{}, NewMapStore(), 1, RoundRobin(1))
+}
+
+func NewSantaRobotPath() *SantaRobotPath {
+	return newMultiAgentPath(Square{}, NewMapStore(), 2, RoundRobin(2))
+}
+
+// NewMultiAgentPath creates a path on the square grid of the given number of agents, from 1 to MaxAgents
+func NewMultiAgentPath(agents int, dispatch Dispatcher) (*MultiAgentPath, error) {
+	return NewMultiAgentPathOn(Square{}, agents, dispatch)
+}
+
+// NewMultiAgentPathOn creates a path on the grid of the coordinate system
+func NewMultiAgentPathOn(coords CoordSystem, agents int, dispatch Dispatcher) (*MultiAgentPath, error) {
+	return NewMultiAgentPathWith(coords, NewMapStore(), agents, dispatch)
+}
+
+// NewMultiAgentPathWith creates a path which keeps the visited houses in the empty store.
+// Statistics of the path are as detailed as the store keeps them.
+func NewMultiAgentPathWith(coords CoordSystem, store VisitStore, agents int, dispatch Dispatcher) (*MultiAgentPath, error) {
+	if coords == nil {
+		return nil, errors.New("coordinate system is not set")
+	}
+	if store == nil || store.Len() != 0 {
+		return nil, errors.New("store must be empty")
+	}
+	if agents < 1 || agents > MaxAgents {
+		return nil, fmt.Errorf("number of agents must be between 1 and %d, got %d", MaxAgents, agents)
+	}
+	if dispatch == nil {
+		return nil, errors.New("dispatcher is not set")
+	}
+	// the built-in dispatchers divide the step by their length
+	switch d := dispatch.(type) {
+	case RoundRobin:
+		if d < 1 {
+			return nil, fmt.Errorf("round robin of %d agents", d)
+		}
+	case Schedule:
+		if len(d) == 0 {
+			return nil, errors.New("schedule is empty")
+		}
 	}
+	return newMultiAgentPath(coords, store, agents, dispatch), nil
 }
 
-func (sp *SantaPath) Move(d rune) error {
-	var err error
-	sp.current, err = shift(sp.current, d)
-	if err != nil {
-		return err
+func newMultiAgentPath(coords CoordSystem, store VisitStore, agents int, dispatch Dispatcher) *MultiAgentPath {
+	return &MultiAgentPath{
+		coords:    coords,
+		agents:    make([]Point, agents),
+		dispatch:  dispatch,
+		innerPath: newInnerPath(store, agents),
 	}
-	sp.visited[sp.current] = struct{}{}
-	return nil
 }
 
-type SantaRobotPath struct {
-	currentSanta Point
-	currentRobot Point
-	santaMoves   bool
-	innerPath
+// Positions returns the current point of every agent
+func (mp *MultiAgentPath) Positions() []Point {
+	return slices.Clone(mp.agents)
 }
 
-func NewSantaRobotPath() *SantaRobotPath {
-	startPoint := Point{0, 0}
-	return &SantaRobotPath{
-		currentSanta: startPoint,
-		currentRobot: startPoint,
-		santaMoves:   true,
-		innerPath:    innerPath{visited: PointSet{startPoint: struct{}{}}},
-	}
+// Agents returns the number of agents
+func (mp *MultiAgentPath) Agents() int {
+	return len(mp.agents)
 }
 
-func (srp *SantaRobotPath) Move(d rune) error {
-	var err error
-	var p Point
-	if srp.santaMoves {
-		p, err = shift(srp.currentSanta, d)
-		srp.currentSanta = p
-	} else {
-		p, err = shift(srp.currentRobot, d)
-		srp.currentRobot = p
+// Next returns the agent which makes the next move
+func (mp *MultiAgentPath) Next() int {
+	return mp.dispatch.Agent(mp.step)
+}
+
+// RecordTrail starts recording every following move
+func (mp *MultiAgentPath) RecordTrail() {
+	if !mp.recording {
+		mp.trailFrom = slices.Clone(mp.agents)
 	}
+	mp.recording = true
+}
+
+// Trail returns the recorded moves in order
+func (mp *MultiAgentPath) Trail() []Step {
+	return mp.trail
+}
+
+// Coords returns the coordinate system of the path
+func (mp *MultiAgentPath) Coords() CoordSystem {
+	return mp.coords
+}
+
+func (mp *MultiAgentPath) Move(d rune) error {
+	delta, err := mp.coords.Delta(d)
 	if err != nil {
 		return err
 	}
-	srp.santaMoves = !srp.santaMoves
+	return mp.MoveBy(delta)
+}
+
+// MoveBy moves the next agent by the delta of a direction table
+func (mp *MultiAgentPath) MoveBy(delta Point) error {
+	a := mp.Next()
+	if a < 0 || a >= len(mp.agents) {
+		return fmt.Errorf("dispatcher chose agent %d of %d", a, len(mp.agents))
+	}
+	p := mp.agents[a].add(delta)
+	mp.agents[a] = p
+	mp.step++
+	if mp.recording {
+		mp.trail = append(mp.trail, Step{a, p})
+	}
 
-	srp.visited[p] = struct{}{}
+	mp.visit(p, a, 1)
 	return nil
 }
 
@@ -111,41 +240,250 @@ func CalcMovement(r rune, pm ...PathMover) error {
 	return nil
 }
 
-func Process(reader io.Reader) (int, int, error) {
-	bufReader := bufio.NewReader(reader)
-	santa := NewSantaPath()
-	roboSants := NewSantaRobotPath()
+// Move moves all paths by every instruction of the reader.
+// A SymbolError of a bad instruction has its offset in the reader.
+func Move(reader io.Reader, pm ...PathMover) error {
+	_, err := moveRunes(reader, false, pm)
+	return err
+}
+
+// MoveLenient is Move which skips the symbols rejected by the paths and returns their number
+func MoveLenient(reader io.Reader, pm ...PathMover) (int, error) {
+	return moveRunes(reader, true, pm)
+}
 
+func moveRunes(reader io.Reader, lenient bool, pm []PathMover) (int, error) {
+	bufReader := bufio.NewReader(reader)
+	var offset int64
+	skipped := 0
 	for {
-		r, _, err := bufReader.ReadRune()
+		r, size, err := bufReader.ReadRune()
 		if err == io.EOF {
 			break
 		}
-		err = CalcMovement(r, santa, roboSants)
 		if err != nil {
-			return 0, 0, err
+			return skipped, err
+		}
+		if lenient {
+			rejected, err := moveAccepting(r, pm)
+			if err != nil {
+				return skipped, err
+			}
+			if rejected {
+				skipped++
+			}
+		} else {
+			err = CalcMovement(r, pm...)
+			if err != nil {
+				return skipped, atOffset(err, offset)
+			}
+		}
+		offset += int64(size)
+	}
+	return skipped, nil
+}
+
+// moveAccepting moves the paths which accept the symbol. Errors other than SymbolError are returned.
+func moveAccepting(r rune, pm []PathMover) (bool, error) {
+	rejected := false
+	for _, m := range pm {
+		err := m.Move(r)
+		var se *SymbolError
+		if errors.As(err, &se) {
+			rejected = true
+			continue
+		}
+		if err != nil {
+			return rejected, err
 		}
 	}
+	return rejected, nil
+}
+
+func Process(reader io.Reader) (int, int, error) {
+	santa := NewSantaPath()
+	roboSants := NewSantaRobotPath()
+	err := Move(reader, santa, roboSants)
+	if err != nil {
+		return 0, 0, err
+	}
 	return santa.Len(), roboSants.Len(), nil
 }
 
-func RunFile() error {
+// ProcessLenient is Process which skips bad symbols, like the trailing newline, and returns their number
+func ProcessLenient(reader io.Reader) (int, int, int, error) {
+	santa := NewSantaPath()
+	roboSants := NewSantaRobotPath()
+	skipped, err := MoveLenient(reader, santa, roboSants)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return santa.Len(), roboSants.Len(), skipped, nil
+}
+
+// RunOptions are the options of RunFile
+type RunOptions struct {
+	// PNG and SVG are the files to render the Santa and Robot path into, empty to skip
+	PNG, SVG string
+	// Directions is the direction table with run-length counts,
+	// without it the input must consist of <>^v only
+	Directions Directions
+	// Lenient skips bad symbols instead of failing
+	Lenient bool
+	// NewStore creates the stores of visited houses, MapStore by default
+	NewStore func() VisitStore
+	// Geometry prints the trajectory queries of Santa alone
+	Geometry bool
+	// Workers splits the input between the number of goroutines if it is set.
+	// The paths are not rendered then.
+	Workers int
+}
+
+// RunFile prints the answers for input.txt
+func RunFile(opts RunOptions) error {
 	file, err := os.Open("input.txt")
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	houseCount1, houseCount2, err := Process(file)
+	newStore := opts.NewStore
+	if newStore == nil {
+		newStore = VisitStores["map"]
+	}
+	santa := newMultiAgentPath(Square{}, newStore(), 1, RoundRobin(1))
+	roboSants := newMultiAgentPath(Square{}, newStore(), 2, RoundRobin(2))
+	if opts.PNG != "" || opts.SVG != "" {
+		// fail before the input is read, the rendering would fail after it
+		if err = roboSants.counted(); err != nil {
+			return err
+		}
+		roboSants.RecordTrail()
+	}
+	if opts.Geometry {
+		santa.RecordTrail()
+	}
+
+	skipped := 0
+	switch {
+	case opts.Workers > 0:
+		if opts.Directions != nil || opts.PNG != "" || opts.SVG != "" || opts.Geometry {
+			return errors.New("parallel mode cannot be used with directions, rendering and geometry")
+		}
+		skipped, err = moveFileParallel(file, ParallelOptions{Workers: opts.Workers, Lenient: opts.Lenient}, santa, roboSants)
+	case opts.Directions != nil:
+		ir := NewInstructionReader(file, opts.Directions)
+		ir.Lenient = opts.Lenient
+		err = moveInstructions(ir, santa, roboSants)
+		skipped = ir.Skipped()
+	case opts.Lenient:
+		skipped, err = MoveLenient(file, santa, roboSants)
+	default:
+		err = Move(file, santa, roboSants)
+	}
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Houses visited: %d, Robot visited %d", houseCount1, houseCount2)
+	fmt.Printf("Houses visited: %d, Robot visited %d", santa.Len(), roboSants.Len())
+	if skipped > 0 {
+		fmt.Printf(", skipped symbols %d", skipped)
+	}
+	if opts.Geometry {
+		fmt.Println()
+		t, err := santa.Trajectory(0)
+		if err != nil {
+			return err
+		}
+		err = t.WriteGeometry(os.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+
+	renders := []struct {
+		name   string
+		render func(io.Writer, *MultiAgentPath, RenderOptions) error
+	}{{opts.PNG, RenderPNG}, {opts.SVG, RenderSVG}}
+	for _, r := range renders {
+		if r.name == "" {
+			continue
+		}
+		err = renderFile(r.name, roboSants, r.render)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// moveFileParallel moves every path by the whole file with MoveParallel
+func moveFileParallel(file *os.File, opts ParallelOptions, paths ...*MultiAgentPath) (int, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	skipped := 0
+	for _, mp := range paths {
+		skipped, err = MoveParallel(file, info.Size(), mp, opts)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return skipped, nil
+}
+
+func renderFile(name string, mp *MultiAgentPath, render func(io.Writer, *MultiAgentPath, RenderOptions) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = render(f, mp, RenderOptions{})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 func main() {
-	err := RunFile()
+	pngFile := flag.String("png", "", "render the Santa and Robot path into the PNG file")
+	svgFile := flag.String("svg", "", "render the Santa and Robot path into the SVG file")
+	replay := flag.Bool("replay", false, "replay the Santa and Robot path on the terminal")
+	delay := flag.Duration("delay", 100*time.Millisecond, "pause between the replayed steps")
+	step := flag.Bool("step", false, "replay a step after every Enter instead of the delay")
+	radius := flag.Int("radius", 5, "houses shown around the agents in the replay")
+	alphabet := flag.String("directions", "", "direction table with run-length counts: arrows, diagonal or compass")
+	lenient := flag.Bool("lenient", false, "skip bad symbols, like the trailing newline, instead of failing")
+	geometry := flag.Bool("geometry", false, "print the bounds, the farthest house, revisits and distinct edges of Santa alone")
+	workers := flag.Int("workers", 0, "split the input between the number of goroutines, 0 to read it sequentially")
+	store := flag.String("store", "map", "store of visited houses: map or tiles (compact, without visit counts)")
+	flag.Parse()
+
+	newStore, ok := VisitStores[*store]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown store %s", *store)
+		os.Exit(-1)
+	}
+
+	var dirs Directions
+	if *alphabet != "" {
+		dirs, ok = DirectionTables[*alphabet]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown direction table %s", *alphabet)
+			os.Exit(-1)
+		}
+	}
+
+	if *workers < 0 {
+		fmt.Fprintf(os.Stderr, "-workers cannot be negative")
+		os.Exit(-1)
+	}
+
+	var err error
+	if *replay {
+		err = ReplayFile(ReplayOptions{Radius: *radius, Delay: *delay, Step: *step, Clear: true, Directions: dirs, Lenient: *lenient})
+	} else {
+		err = RunFile(RunOptions{PNG: *pngFile, SVG: *svgFile, Directions: dirs, Lenient: *lenient, NewStore: newStore, Geometry: *geometry, Workers: *workers})
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v", err)
 		os.Exit(-1)
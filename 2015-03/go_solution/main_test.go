@@ -1,6 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -33,3 +41,764 @@ func TestProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiAgentPath(t *testing.T) {
+	weighted, err := NewWeighted(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := ParseSchedule("0, 1,1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name     string
+		agents   int
+		dispatch Dispatcher
+		input    string
+		want     int
+	}{
+		{"santa", 1, RoundRobin(1), "^v^v^v^v^v", 2},
+		{"santa and robot", 2, RoundRobin(2), "^v^v^v^v^v", 11},
+		{"three agents", 3, RoundRobin(3), "^^^>>>", 3},
+		{"hundred agents", 100, RoundRobin(100), strings.Repeat("^", 100) + strings.Repeat(">", 100), 3},
+		{"weighted", 2, weighted, "^^v^^v", 7},
+		{"schedule", 2, schedule, "^>>^>>", 7},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mp, err := NewMultiAgentPath(tc.agents, tc.dispatch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range tc.input {
+				err = mp.Move(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if mp.Len() != tc.want {
+				t.Errorf("want: %d, got: %d", tc.want, mp.Len())
+			}
+		})
+	}
+}
+
+func TestMultiAgentPathErrors(t *testing.T) {
+	if _, err := NewMultiAgentPath(0, RoundRobin(1)); err == nil {
+		t.Error("want error for 0 agents")
+	}
+	if _, err := NewMultiAgentPath(MaxAgents+1, RoundRobin(MaxAgents+1)); err == nil {
+		t.Error("want error for too many agents")
+	}
+	if _, err := ParseSchedule("0,2", 2); err == nil {
+		t.Error("want error for agent out of range")
+	}
+	if _, err := NewWeighted(0, 0); err == nil {
+		t.Error("want error for zero weights")
+	}
+	if _, err := ParseSchedule(" ", 2); err == nil {
+		t.Error("want error for empty schedule")
+	}
+	if _, err := NewMultiAgentPath(2, RoundRobin(0)); err == nil {
+		t.Error("want error for round robin of 0 agents")
+	}
+	if _, err := NewMultiAgentPath(2, Schedule{}); err == nil {
+		t.Error("want error for empty schedule")
+	}
+	mp, err := NewMultiAgentPath(2, Schedule{0, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Move('^'); err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Move('^'); err == nil {
+		t.Error("want error for agent out of range")
+	}
+}
+
+func TestVisitStatistics(t *testing.T) {
+	sp := NewSantaRobotPath()
+	for _, r := range "^v^v>>" {
+		if err := sp.Move(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// santa: (0,1) (0,2) (1,2); robot: (0,-1) (0,-2) (1,-2)
+	start := sp.Visits(Point{0, 0, 0})
+	if start.Count != 2 || !start.Agents.Has(0) || !start.Agents.Has(1) {
+		t.Errorf("unexpected start visit %+v", start)
+	}
+	if v := sp.Visits(Point{1, 2, 0}); v.Count != 1 || !v.Agents.Has(0) || v.Agents.Has(1) || v.Agents.Len() != 1 {
+		t.Errorf("unexpected visit %+v", v)
+	}
+	if v := sp.Visits(Point{5, 5, 0}); v.Count != 0 {
+		t.Errorf("unexpected visit %+v", v)
+	}
+
+	top, err := sp.TopVisited(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Point != (Point{0, 0, 0}) || top[1].Point != (Point{0, -2, 0}) {
+		t.Errorf("unexpected top %+v", top)
+	}
+	for _, n := range []int{0, -1} {
+		if top, err := sp.TopVisited(n); err != nil || len(top) != 0 {
+			t.Errorf("top %d: unexpected %+v, %v", n, top, err)
+		}
+	}
+	once, err := sp.VisitedOnce()
+	wantOnce := []Point{{0, -2, 0}, {1, -2, 0}, {0, -1, 0}, {0, 1, 0}, {0, 2, 0}, {1, 2, 0}}
+	if err != nil || !slices.Equal(once, wantOnce) {
+		t.Errorf("want: %v, got: %v, %v", wantOnce, once, err)
+	}
+	if h, err := sp.Histogram(); err != nil || !slices.Equal(h, []int{0, 6, 1}) {
+		t.Errorf("unexpected histogram %v, %v", h, err)
+	}
+	if n, err := sp.AtLeast(2); err != nil || n != 1 {
+		t.Errorf("want: 1, got: %d, %v", n, err)
+	}
+
+	mp, err := NewMultiAgentPath(MaxAgents, RoundRobin(MaxAgents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Move('^'); err != nil {
+		t.Fatal(err)
+	}
+	if v := mp.Visits(Point{0, 0, 0}); v.Count != MaxAgents || v.Agents.Len() != MaxAgents || !v.Agents.Has(MaxAgents-1) {
+		t.Errorf("unexpected start visit %+v", v)
+	}
+}
+
+func TestRender(t *testing.T) {
+	mp := NewSantaRobotPath()
+	mp.RecordTrail()
+	err := Move(strings.NewReader("^v>>"), mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mp.Trail()) != 4 || mp.Trail()[3] != (Step{1, Point{1, -1, 0}}) {
+		t.Errorf("unexpected trail %v", mp.Trail())
+	}
+	minP, maxP := mp.Bounds()
+	if minP != (Point{0, -1, 0}) || maxP != (Point{1, 1, 0}) {
+		t.Errorf("unexpected bounds %v %v", minP, maxP)
+	}
+
+	var buf bytes.Buffer
+	err = RenderPNG(&buf, mp, RenderOptions{Scale: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 3 {
+		t.Errorf("unexpected image size %v", b)
+	}
+	// the house at (1, 0) is not visited, santa's house is on the top left
+	if _, _, _, a := img.At(1, 1).RGBA(); a != 0xffff {
+		t.Errorf("unexpected alpha %x", a)
+	}
+	if r, g, b, _ := img.At(1, 1).RGBA(); r|g|b != 0 {
+		t.Errorf("unvisited house is not black")
+	}
+	c, err := newCanvas(mp, RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	santa := c.houseColor(mp.Visits(Point{0, 1, 0}))
+	if img.At(0, 0) != color.Color(santa) || santa.R <= santa.G {
+		t.Errorf("want santa colour %v, got %v", santa, img.At(0, 0))
+	}
+
+	buf.Reset()
+	err = RenderSVG(&buf, mp, RenderOptions{Scale: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	for _, want := range []string{`width="20" height="30"`, `points="5,15 5,5 15,5"`, `points="5,15 5,25 15,25"`, "<title>0,0: 2</title>"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("svg has no %s:\n%s", want, svg)
+		}
+	}
+
+	err = RenderPNG(io.Discard, mp, RenderOptions{Scale: 1 << 16})
+	if err == nil {
+		t.Error("want error for a too large image")
+	}
+
+	// the trail recorded in the middle starts where the agents are
+	mp = NewSantaRobotPath()
+	err = Move(strings.NewReader("^v"), mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.RecordTrail()
+	err = Move(strings.NewReader(">>"), mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	err = RenderSVG(&buf, mp, RenderOptions{Scale: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg = buf.String()
+	for _, want := range []string{`points="5,5 15,5"`, `points="5,25 15,25"`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("svg has no %s:\n%s", want, svg)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	err := Replay(strings.NewReader("^>v"), &buf, strings.NewReader("\n\n"), NewSantaRobotPath(), ReplayOptions{Radius: 1, Step: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the input has only two lines, so the replay stops after the third frame
+	want := `step 1: ^ moves A, houses 2
+A (0, 1)
+B (0, 0)
+...
+.A.
+.B.
+...
+step 2: > moves B, houses 3
+A (0, 1)
+B (1, 0)
+....
+.A..
+.2B.
+....
+step 3: v moves A, houses 3
+A (0, 0)
+B (1, 0)
+.1..
+.AB.
+....
+`
+	if buf.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	err = Replay(strings.NewReader("^x"), &buf, nil, NewSantaPath(), ReplayOptions{})
+	if err == nil || !strings.Contains(err.Error(), "step 2") {
+		t.Errorf("want error of step 2, got: %v", err)
+	}
+
+	// run-length instructions are replayed move by move, bad symbols are skipped
+	buf.Reset()
+	err = Replay(strings.NewReader("x>2\n"), &buf, nil, NewSantaPath(), ReplayOptions{Directions: ArrowDirections, Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"step 1: > moves A, houses 2\nA (1, 0)\n", "step 2: > moves A, houses 3\nA (2, 0)\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("replay has no %q:\n%s", want, buf.String())
+		}
+	}
+	buf.Reset()
+	err = Replay(strings.NewReader("^x\n"), &buf, nil, NewSantaPath(), ReplayOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "step ") != 1 {
+		t.Errorf("want a single frame, got:\n%s", buf.String())
+	}
+}
+
+func TestMoveInstructions(t *testing.T) {
+	testCases := []struct {
+		name  string
+		dirs  Directions
+		input string
+		want  int
+	}{
+		{"arrows", ArrowDirections, "^v^v^v^v^v\n", 2},
+		{"run-length", ArrowDirections, "^12 >3\n", 16},
+		{"zero count", ArrowDirections, "^0>", 2},
+		{"diagonal", DiagonalDirections, "qezc", 3},
+		{"compass", CompassDirections, "N E\tS W\r\n", 4},
+		{"compass diagonal", CompassDirections, "NE2SW2", 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sp := NewSantaPath()
+			err := MoveInstructions(strings.NewReader(tc.input), tc.dirs, sp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sp.Len() != tc.want {
+				t.Errorf("want: %d, got: %d", tc.want, sp.Len())
+			}
+		})
+	}
+
+	// a repeated instruction is the same as the expanded one, even for turns of agents
+	runLength, expanded := NewSantaRobotPath(), NewSantaRobotPath()
+	err := MoveInstructions(strings.NewReader("^3>2v1<4"), ArrowDirections, runLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Move(strings.NewReader("^^^>>v<<<<"), expanded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runLength.Len() != expanded.Len() || !slices.Equal(runLength.Positions(), expanded.Positions()) {
+		t.Errorf("run-length path %v differs from expanded %v", runLength.Positions(), expanded.Positions())
+	}
+
+	err = MoveInstructions(strings.NewReader("^2 x"), ArrowDirections, NewSantaPath())
+	if err == nil || err.Error() != `unexpected symbol 'x' at offset 3` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCoordSystems(t *testing.T) {
+	testCases := []struct {
+		name         string
+		coords       CoordSystem
+		agents       int
+		input        string
+		want         int
+		wantDistance int
+	}{
+		{"square", Square{}, 1, "^>v<", 4, 0},
+		// six moves around a hexagon come back to the start
+		{"hex ring", Hex{}, 1, "dxzawe", 6, 0},
+		{"hex line", Hex{}, 1, "ee", 3, 2},
+		{"hex back and forth", Hex{}, 2, "wwxx", 2, 0},
+		{"cube", Cube{}, 1, "^uv>d", 6, 1},
+		{"cube tower", Cube{}, 1, "uuudd", 4, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mp, err := NewMultiAgentPathOn(tc.coords, tc.agents, RoundRobin(tc.agents))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range tc.input {
+				if err = mp.Move(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if mp.Len() != tc.want {
+				t.Errorf("want: %d, got: %d", tc.want, mp.Len())
+			}
+			if d := tc.coords.Distance(Point{}, mp.Positions()[0]); d != tc.wantDistance {
+				t.Errorf("want distance: %d, got: %d", tc.wantDistance, d)
+			}
+
+			// the direction table gives the same path as single symbols
+			tp, err := NewMultiAgentPathOn(tc.coords, tc.agents, RoundRobin(tc.agents))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = MoveInstructions(strings.NewReader(tc.input), tc.coords.Directions(), tp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(tp.Positions(), mp.Positions()) {
+				t.Errorf("direction table moves to %v, symbols move to %v", tp.Positions(), mp.Positions())
+			}
+		})
+	}
+
+	hp, err := NewMultiAgentPathOn(Hex{}, 1, RoundRobin(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = hp.Move('^'); err == nil {
+		t.Error("want error for ^ on the hex grid")
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	testCases := []struct {
+		input     string
+		wantError string
+		wantSkip  int
+		wantSanta int
+	}{
+		{"^>v<\n", `unexpected symbol '\n' at offset 4`, 1, 4},
+		{"^é>", `unexpected symbol 'é' at offset 1`, 1, 3},
+		{"^ >x\r\n", `unexpected symbol ' ' at offset 1`, 4, 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			_, _, err := Process(strings.NewReader(tc.input))
+			var se *SymbolError
+			if !errors.As(err, &se) {
+				t.Fatalf("want SymbolError, got %v", err)
+			}
+			if err.Error() != tc.wantError {
+				t.Errorf("want: %s, got: %s", tc.wantError, err)
+			}
+
+			gotSanta, _, skipped, err := ProcessLenient(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skipped != tc.wantSkip || gotSanta != tc.wantSanta {
+				t.Errorf("want: %d skipped %d houses, got: %d skipped %d houses", tc.wantSkip, tc.wantSanta, skipped, gotSanta)
+			}
+		})
+	}
+
+	ir := NewInstructionReader(strings.NewReader("^2x>y\n"), ArrowDirections)
+	ir.Lenient = true
+	sp := NewSantaPath()
+	err := moveInstructions(ir, sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.Skipped() != 2 || sp.Len() != 4 {
+		t.Errorf("want: 2 skipped 4 houses, got: %d skipped %d houses", ir.Skipped(), sp.Len())
+	}
+}
+
+// randomWalk returns n pseudo-random arrows, the same for every call
+func randomWalk(n int) string {
+	var sb strings.Builder
+	sb.Grow(n)
+	seed := uint32(1)
+	for i := 0; i < n; i++ {
+		seed = seed*1664525 + 1013904223
+		sb.WriteByte("<>^v"[seed>>30])
+	}
+	return sb.String()
+}
+
+func TestTileStore(t *testing.T) {
+	input := randomWalk(100_000)
+	mp, err := NewMultiAgentPathWith(Square{}, NewMapStore(), 3, RoundRobin(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := NewMultiAgentPathWith(Square{}, NewTileStore(), 3, RoundRobin(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Move(strings.NewReader(input), mp, tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mp.Len() != tp.Len() {
+		t.Errorf("map store: %d houses, tile store: %d houses", mp.Len(), tp.Len())
+	}
+
+	count := 0
+	for p, v := range tp.store.All() {
+		count++
+		if v.Count != 1 || mp.Visits(p).Count == 0 {
+			t.Fatalf("house %v is not visited", p)
+		}
+	}
+	if count != mp.Len() {
+		t.Errorf("want: %d houses, got: %d", mp.Len(), count)
+	}
+	if v := tp.Visits(Point{-1000, 1000, 0}); v.Count != 0 {
+		t.Errorf("unexpected visit %+v", v)
+	}
+
+	if _, err = NewMultiAgentPathWith(Square{}, tp.store, 1, RoundRobin(1)); err == nil {
+		t.Error("want error for not empty store")
+	}
+
+	// the tile store has no counts for the statistics and rendering
+	if _, err = tp.Histogram(); !errors.Is(err, ErrPresenceOnly) {
+		t.Errorf("want: %v, got: %v", ErrPresenceOnly, err)
+	}
+	if _, err = tp.TopVisited(10); !errors.Is(err, ErrPresenceOnly) {
+		t.Errorf("want: %v, got: %v", ErrPresenceOnly, err)
+	}
+	if err = RenderSVG(io.Discard, tp, RenderOptions{}); !errors.Is(err, ErrPresenceOnly) {
+		t.Errorf("want: %v, got: %v", ErrPresenceOnly, err)
+	}
+}
+
+// BenchmarkStores reports the heap used for every visited house
+func BenchmarkStores(b *testing.B) {
+	input := randomWalk(1_000_000)
+	for _, name := range []string{"map", "tiles"} {
+		b.Run(name, func(b *testing.B) {
+			var before, after runtime.MemStats
+			var houses int
+			var heap uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				mp := newMultiAgentPath(Square{}, VisitStores[name](), 1, RoundRobin(1))
+				err := Move(strings.NewReader(input), mp)
+				if err != nil {
+					b.Fatal(err)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				houses = mp.Len()
+				heap = after.HeapAlloc - before.HeapAlloc
+				runtime.KeepAlive(mp)
+			}
+			b.ReportMetric(float64(heap)/float64(houses), "B/house")
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(input)), "ns/step")
+		})
+	}
+}
+
+func TestTrajectory(t *testing.T) {
+	sp := NewSantaPath()
+	sp.RecordTrail()
+	// a square loop, there and back along its top edge and two steps up
+	err := Move(strings.NewReader("^>v<^><^^"), sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := sp.Trajectory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Points) != 10 {
+		t.Fatalf("want: 10 points, got: %v", tr.Points)
+	}
+	minP, maxP := tr.Bounds()
+	if minP != (Point{0, 0, 0}) || maxP != (Point{1, 3, 0}) {
+		t.Errorf("unexpected bounds %v %v", minP, maxP)
+	}
+	if p, d := tr.Farthest(); p != (Point{0, 3, 0}) || d != 3 {
+		t.Errorf("want farthest (0, 3) at 3, got %v at %d", p, d)
+	}
+	if p, step, ok := tr.FirstRevisit(); !ok || p != (Point{0, 0, 0}) || step != 4 {
+		t.Errorf("want first revisit (0, 0) at 4, got %v at %d", p, step)
+	}
+	want := []Intersection{
+		{Point{0, 0, 0}, []int{0, 4}},
+		{Point{0, 1, 0}, []int{1, 5, 7}},
+		{Point{1, 1, 0}, []int{2, 6}},
+	}
+	got := tr.Intersections()
+	if len(got) != len(want) {
+		t.Fatalf("want: %v, got: %v", want, got)
+	}
+	for i := range want {
+		if got[i].Point != want[i].Point || !slices.Equal(got[i].Steps, want[i].Steps) {
+			t.Errorf("want: %v, got: %v", want[i], got[i])
+		}
+	}
+	if n := tr.DistinctEdges(); n != 6 {
+		t.Errorf("want: 6 distinct edges, got: %d", n)
+	}
+
+	robots := NewSantaRobotPath()
+	robots.RecordTrail()
+	if err = Move(strings.NewReader("^v^"), robots); err != nil {
+		t.Fatal(err)
+	}
+	if tr, err = robots.Trajectory(1); err != nil || !slices.Equal(tr.Points, []Point{{0, 0, 0}, {0, -1, 0}}) {
+		t.Errorf("unexpected robot trajectory %v, %v", tr.Points, err)
+	}
+
+	// distances and points are of the grid of the path
+	hex, err := NewMultiAgentPathOn(Hex{}, 1, RoundRobin(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hex.RecordTrail()
+	if err = Move(strings.NewReader("ee"), hex); err != nil {
+		t.Fatal(err)
+	}
+	if tr, err = hex.Trajectory(0); err != nil {
+		t.Fatal(err)
+	}
+	if p, d := tr.Farthest(); p != (Point{2, -2, 0}) || d != 2 {
+		t.Errorf("want farthest (2, -2) at 2, got %v at %d", p, d)
+	}
+	cube, err := NewMultiAgentPathOn(Cube{}, 1, RoundRobin(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cube.RecordTrail()
+	if err = Move(strings.NewReader("uu>"), cube); err != nil {
+		t.Fatal(err)
+	}
+	if tr, err = cube.Trajectory(0); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = tr.WriteGeometry(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "bounds (0, 0, 0) - (1, 0, 2), farthest (1, 0, 2) at 3,"; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("want: %s, got: %s", want, buf.String())
+	}
+
+	// an empty trajectory has no moves
+	var empty Trajectory
+	if p, d := empty.Farthest(); p != (Point{}) || d != 0 {
+		t.Errorf("unexpected farthest of empty trajectory %v at %d", p, d)
+	}
+	buf.Reset()
+	if err = empty.WriteGeometry(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "bounds (0, 0) - (0, 0), farthest (0, 0) at 0, distinct edges 0 of 0 moves\nno revisits\n"; buf.String() != want {
+		t.Errorf("want: %q, got: %q", want, buf.String())
+	}
+
+	notRecorded := NewSantaPath()
+	if err = Move(strings.NewReader("^"), notRecorded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = notRecorded.Trajectory(0); err == nil {
+		t.Error("want error for not recorded trail")
+	}
+}
+
+func TestMoveParallel(t *testing.T) {
+	weighted, err := NewWeighted(3, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk := randomWalk(20_000)
+	testCases := []struct {
+		name     string
+		input    string
+		agents   int
+		dispatch Dispatcher
+		lenient  bool
+	}{
+		{"santa", walk, 1, RoundRobin(1), false},
+		{"santa and robot", walk, 2, RoundRobin(2), false},
+		{"weighted agents", walk, 3, weighted, false},
+		{"hundred agents", walk, 100, RoundRobin(100), false},
+		{"lenient", "^>é<v\n" + walk[:1000] + "x\n", 2, RoundRobin(2), true},
+	}
+	for _, tc := range testCases {
+		for _, chunkSize := range []int64{1, 2, 7, 1000, 1 << 20} {
+			t.Run(fmt.Sprintf("%s/%d", tc.name, chunkSize), func(t *testing.T) {
+				want, err := NewMultiAgentPath(tc.agents, tc.dispatch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantSkipped := 0
+				if tc.lenient {
+					wantSkipped, err = MoveLenient(strings.NewReader(tc.input), want)
+				} else {
+					err = Move(strings.NewReader(tc.input), want)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := NewMultiAgentPath(tc.agents, tc.dispatch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts := ParallelOptions{Workers: 3, ChunkSize: chunkSize, Lenient: tc.lenient}
+				skipped, err := MoveParallel(strings.NewReader(tc.input), int64(len(tc.input)), got, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if skipped != wantSkipped {
+					t.Errorf("want: %d skipped, got: %d", wantSkipped, skipped)
+				}
+				wantHist, err := want.Histogram()
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotHist, err := got.Histogram()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Len() != want.Len() || !slices.Equal(gotHist, wantHist) {
+					t.Errorf("want: %d houses %v, got: %d houses %v", want.Len(), wantHist, got.Len(), gotHist)
+				}
+				if !slices.Equal(got.Positions(), want.Positions()) || got.Next() != want.Next() {
+					t.Errorf("want positions %v, got %v", want.Positions(), got.Positions())
+				}
+				wantTop, err := want.TopVisited(10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotTop, err := got.TopVisited(10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(gotTop, wantTop) {
+					t.Errorf("want top %v, got %v", wantTop, gotTop)
+				}
+			})
+		}
+	}
+}
+
+func TestProcessParallel(t *testing.T) {
+	for _, input := range []string{"", ">", "^>v<", "^v^v^v^v^v", randomWalk(10_000)} {
+		wantSanta, wantRobo, err := Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotSanta, gotRobo, err := ProcessParallel(strings.NewReader(input), int64(len(input)), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotSanta != wantSanta || gotRobo != wantRobo {
+			t.Errorf("want: %d %d, got: %d %d", wantSanta, wantRobo, gotSanta, gotRobo)
+		}
+	}
+
+	// errors are the same as the sequential ones
+	for _, input := range []string{"^>v<\n", "^é>", strings.Repeat("^", 100) + "é"} {
+		_, _, want := Process(strings.NewReader(input))
+		for _, chunkSize := range []int64{1, 3, 1 << 20} {
+			mp := NewSantaRobotPath()
+			_, got := MoveParallel(strings.NewReader(input), int64(len(input)), mp, ParallelOptions{Workers: 2, ChunkSize: chunkSize})
+			if got == nil || got.Error() != want.Error() {
+				t.Errorf("%q by %d: want: %v, got: %v", input, chunkSize, want, got)
+			}
+		}
+	}
+
+	// an error of the second pass stops all the workers before the return
+	before := runtime.NumGoroutine()
+	mp, err := NewMultiAgentPath(2, RoundRobin(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := randomWalk(10_000)
+	_, err = MoveParallel(strings.NewReader(input), int64(len(input)), mp, ParallelOptions{Workers: 4, ChunkSize: 100})
+	if err == nil || !strings.Contains(err.Error(), "dispatcher chose agent 2") {
+		t.Errorf("want dispatcher error, got: %v", err)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("want: %d goroutines, got: %d", before, n)
+	}
+}
+
+func BenchmarkProcess(b *testing.B) {
+	input := randomWalk(1_000_000)
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _, err := Process(strings.NewReader(input))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _, err := ProcessParallel(strings.NewReader(input), int64(len(input)), runtime.NumCPU())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"unicode/utf8"
+)
+
+// ParallelOptions are the options of MoveParallel
+type ParallelOptions struct {
+	// Workers is the number of goroutines, runtime.NumCPU() by default
+	Workers int
+	// ChunkSize is the size of the parts of the input in bytes, 4 MiB by default
+	ChunkSize int64
+	// Lenient skips bad symbols instead of failing
+	Lenient bool
+}
+
+// chunk is a part of the input from the byte from to the byte to, both are starts of runes
+type chunk struct {
+	from, to int64
+}
+
+// chunkSteps is the number of steps and skipped symbols of a chunk
+type chunkSteps struct {
+	steps, skipped int
+	err            error
+}
+
+// agentPoint is a house visited by the agent relative to the position of the agent at the start of a chunk
+type agentPoint struct {
+	agent int
+	p     Point
+}
+
+// chunkWalk is the walk of all agents in a chunk from zero positions
+type chunkWalk struct {
+	visits map[agentPoint]int
+	moved  []Point
+	err    error
+}
+
+// MoveParallel moves the path by every instruction of r like Move or MoveLenient,
+// but the input is split into chunks which are processed concurrently.
+//
+// The first pass counts the steps of every chunk, so every chunk knows the number of its first step
+// and the agents which make its moves. The second pass walks every chunk from zero positions
+// of the agents. The houses of a chunk are shifted by the moves of the agents in all previous chunks
+// and merged into the path in order, so only a few walks are kept in memory at once.
+//
+// The dispatcher of the path is called concurrently. The trail is not recorded,
+// and the path is incomplete after an error. It returns the number of skipped symbols.
+func MoveParallel(r io.ReaderAt, size int64, mp *MultiAgentPath, opts ParallelOptions) (int, error) {
+	if mp.recording {
+		return 0, errors.New("trail cannot be recorded in parallel")
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 4 << 20
+	}
+	chunks, err := splitChunks(r, size, chunkSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// the first pass: steps of every chunk
+	counts := make([]chunkSteps, len(chunks))
+	forChunks(len(chunks), workers, func(i int) {
+		counts[i] = countSteps(r, chunks[i], mp.coords, opts.Lenient)
+	})
+	firstSteps := make([]int, len(chunks))
+	step, skipped := mp.step, 0
+	for i, c := range counts {
+		if c.err != nil {
+			return skipped, c.err
+		}
+		firstSteps[i] = step
+		step += c.steps
+		skipped += c.skipped
+	}
+
+	// the second pass: walks of the chunks merged in order
+	results := make([]chan chunkWalk, len(chunks))
+	for i := range results {
+		results[i] = make(chan chunkWalk, 1)
+	}
+	// on an early return done stops the spawner and the workers are waited for
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	done := make(chan struct{})
+	defer close(done)
+	window := make(chan struct{}, 2*workers)
+	indexes := make(chan int)
+	wg.Add(1 + workers)
+	go func() {
+		defer wg.Done()
+		defer close(indexes)
+		for i := range chunks {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			indexes <- i
+		}
+	}()
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] <- walkChunk(r, chunks[i], firstSteps[i], mp)
+			}
+		}()
+	}
+
+	for i := range chunks {
+		walk := <-results[i]
+		<-window
+		if walk.err != nil {
+			return skipped, walk.err
+		}
+		mp.merge(walk)
+	}
+	mp.step = step
+	return skipped, nil
+}
+
+// merge adds the houses of the walk and moves the agents to the end of the walk
+func (mp *MultiAgentPath) merge(walk chunkWalk) {
+	for ap, n := range walk.visits {
+		mp.visit(mp.agents[ap.agent].add(ap.p), ap.agent, n)
+	}
+	for a, moved := range walk.moved {
+		mp.agents[a] = mp.agents[a].add(moved)
+	}
+}
+
+// splitChunks splits the input into chunks of about chunkSize bytes.
+// A boundary is moved forward past continuation bytes, so a rune is never split
+// and a chunk decodes into the same runes as the whole input.
+func splitChunks(r io.ReaderAt, size, chunkSize int64) ([]chunk, error) {
+	chunks := make([]chunk, 0, size/chunkSize+1)
+	var b [1]byte
+	for from := int64(0); from < size; {
+		to := min(from+chunkSize, size)
+		for i := 0; i < utf8.UTFMax-1 && to < size; i++ {
+			_, err := r.ReadAt(b[:], to)
+			if err != nil {
+				return nil, err
+			}
+			if utf8.RuneStart(b[0]) {
+				break
+			}
+			to++
+		}
+		chunks = append(chunks, chunk{from, to})
+		from = to
+	}
+	return chunks, nil
+}
+
+// forChunks calls fn for every chunk index from the given number of goroutines
+func forChunks(n, workers int, fn func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// scanChunk calls fn for every rune of the chunk with its offset in the input
+func scanChunk(r io.ReaderAt, c chunk, fn func(d rune, offset int64) error) error {
+	bufReader := bufio.NewReaderSize(io.NewSectionReader(r, c.from, c.to-c.from), int(min(c.to-c.from, 64<<10)))
+	offset := c.from
+	for {
+		d, size, err := bufReader.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(d, offset)
+		if err != nil {
+			return err
+		}
+		offset += int64(size)
+	}
+}
+
+func countSteps(r io.ReaderAt, c chunk, coords CoordSystem, lenient bool) chunkSteps {
+	var cs chunkSteps
+	cs.err = scanChunk(r, c, func(d rune, offset int64) error {
+		_, err := coords.Delta(d)
+		var se *SymbolError
+		switch {
+		case lenient && errors.As(err, &se):
+			cs.skipped++
+			return nil
+		case err != nil:
+			return atOffset(err, offset)
+		}
+		cs.steps++
+		return nil
+	})
+	return cs
+}
+
+func walkChunk(r io.ReaderAt, c chunk, step int, mp *MultiAgentPath) chunkWalk {
+	walk := chunkWalk{visits: make(map[agentPoint]int), moved: make([]Point, len(mp.agents))}
+	walk.err = scanChunk(r, c, func(d rune, offset int64) error {
+		delta, err := mp.coords.Delta(d)
+		if err != nil {
+			// the first pass has checked the symbols, so it is a skipped one
+			return nil
+		}
+		a := mp.dispatch.Agent(step)
+		if a < 0 || a >= len(mp.agents) {
+			return fmt.Errorf("dispatcher chose agent %d of %d at offset %d", a, len(mp.agents), offset)
+		}
+		walk.moved[a] = walk.moved[a].add(delta)
+		walk.visits[agentPoint{a, walk.moved[a]}]++
+		step++
+		return nil
+	})
+	return walk
+}
+
+// ProcessParallel is Process with the input split between the workers
+func ProcessParallel(r io.ReaderAt, size int64, workers int) (int, int, error) {
+	santa := NewSantaPath()
+	roboSants := NewSantaRobotPath()
+	for _, mp := range []*MultiAgentPath{santa, roboSants} {
+		_, err := MoveParallel(r, size, mp, ParallelOptions{Workers: workers})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return santa.Len(), roboSants.Len(), nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"slices"
+)
+
+// maxPixels limits the size of a PNG image, 256 MiB of RGBA pixels
+const maxPixels = 1 << 26
+
+// RenderOptions are the options of RenderPNG and RenderSVG
+type RenderOptions struct {
+	// Scale is the size of a house in pixels, 4 by default
+	Scale int
+}
+
+func (o RenderOptions) scale() int {
+	if o.Scale <= 0 {
+		return 4
+	}
+	return o.Scale
+}
+
+// Bounds returns the smallest box of the visited houses, Max is inclusive
+func (ip innerPath) Bounds() (minP, maxP Point) {
+	first := true
+	for p := range ip.store.All() {
+		if first {
+			minP, maxP, first = p, p, false
+			continue
+		}
+		minP = Point{min(minP.x, p.x), min(minP.y, p.y), min(minP.z, p.z)}
+		maxP = Point{max(maxP.x, p.x), max(maxP.y, p.y), max(maxP.z, p.z)}
+	}
+	return minP, maxP
+}
+
+// canvas maps houses into pixels of an image with north at the top.
+// Floors of cube grids are projected on each other.
+type canvas struct {
+	houses     map[Point]Visit
+	minP, maxP Point
+	scale      int
+	maxCount   int
+	palette    []color.RGBA
+}
+
+func newCanvas(mp *MultiAgentPath, opts RenderOptions) (*canvas, error) {
+	if err := mp.counted(); err != nil {
+		return nil, err
+	}
+	c := &canvas{houses: make(map[Point]Visit), scale: opts.scale(), palette: agentPalette(mp.Agents())}
+	for p, v := range mp.store.All() {
+		p.z = 0
+		h := c.houses[p]
+		h.Count += v.Count
+		for i := range h.Agents {
+			h.Agents[i] |= v.Agents[i]
+		}
+		c.houses[p] = h
+		c.maxCount = max(c.maxCount, h.Count)
+	}
+	c.minP, c.maxP = mp.Bounds()
+	return c, nil
+}
+
+// sortedHouses returns the projected houses ordered by y and then by x
+func (c *canvas) sortedHouses() []HouseVisit {
+	houses := make([]HouseVisit, 0, len(c.houses))
+	for p, v := range c.houses {
+		houses = append(houses, HouseVisit{p, v})
+	}
+	slices.SortFunc(houses, func(a, b HouseVisit) int { return comparePoints(a.Point, b.Point) })
+	return houses
+}
+
+func (c *canvas) size() (int, int) {
+	return (c.maxP.x - c.minP.x + 1) * c.scale, (c.maxP.y - c.minP.y + 1) * c.scale
+}
+
+// corner returns the top left pixel of the house
+func (c *canvas) corner(p Point) (int, int) {
+	return (p.x - c.minP.x) * c.scale, (c.maxP.y - p.y) * c.scale
+}
+
+// houseColor mixes the colours of the agents which visited the house,
+// the more presents the house got, the brighter it is
+func (c *canvas) houseColor(v Visit) color.RGBA {
+	var r, g, b, n int
+	for a, col := range c.palette {
+		if v.Agents.Has(a) {
+			r, g, b, n = r+int(col.R), g+int(col.G), b+int(col.B), n+1
+		}
+	}
+	shade := 1.0
+	if c.maxCount > 1 {
+		shade = 0.35 + 0.65*math.Log(float64(v.Count))/math.Log(float64(c.maxCount))
+	}
+	mix := func(sum int) uint8 { return uint8(float64(sum/n) * shade) }
+	return color.RGBA{mix(r), mix(g), mix(b), 0xff}
+}
+
+// agentPalette returns distinct colours spread over the hue circle
+func agentPalette(agents int) []color.RGBA {
+	palette := make([]color.RGBA, agents)
+	for a := range palette {
+		// the golden angle keeps neighbour agents apart for any number of agents
+		palette[a] = hsvColor(math.Mod(float64(a)*137.508, 360), 0.8, 1)
+	}
+	return palette
+}
+
+func hsvColor(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 0xff}
+}
+
+// RenderPNG draws the visited houses on a black background. The recorded trail
+// is drawn as thin lines of the agent colours over the houses.
+func RenderPNG(w io.Writer, mp *MultiAgentPath, opts RenderOptions) error {
+	c, err := newCanvas(mp, opts)
+	if err != nil {
+		return err
+	}
+	width, height := c.size()
+	if float64(width)*float64(height) > maxPixels {
+		return fmt.Errorf("image %dx%d is larger than %d pixels, use a smaller scale", width, height, maxPixels)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		if i%4 == 3 {
+			img.Pix[i] = 0xff
+		}
+	}
+
+	for p, v := range c.houses {
+		x0, y0 := c.corner(p)
+		col := c.houseColor(v)
+		for y := y0; y < y0+c.scale; y++ {
+			for x := x0; x < x0+c.scale; x++ {
+				img.SetRGBA(x, y, col)
+			}
+		}
+	}
+
+	if c.scale > 2 {
+		c.walkTrail(mp, func(agent int, from, to Point) {
+			x0, y0 := c.corner(from)
+			x1, y1 := c.corner(to)
+			drawLine(img, x0+c.scale/2, y0+c.scale/2, x1+c.scale/2, y1+c.scale/2, c.palette[agent])
+		})
+	}
+	return png.Encode(w, img)
+}
+
+// RenderSVG draws the same picture as RenderPNG as an SVG document with a polyline for every agent
+func RenderSVG(w io.Writer, mp *MultiAgentPath, opts RenderOptions) error {
+	c, err := newCanvas(mp, opts)
+	if err != nil {
+		return err
+	}
+	width, height := c.size()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" fill="black"/>`+"\n", width, height)
+
+	for _, hv := range c.sortedHouses() {
+		x, y := c.corner(hv.Point)
+		col := c.houseColor(hv.Visit)
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="#%02x%02x%02x"><title>%d,%d: %d</title></rect>`+"\n",
+			x, y, c.scale, c.scale, col.R, col.G, col.B, hv.Point.x, hv.Point.y, hv.Count)
+	}
+
+	// one polyline for every agent with all its points in order
+	lines := make([][]Point, mp.Agents())
+	c.walkTrail(mp, func(agent int, from, to Point) {
+		if len(lines[agent]) == 0 {
+			lines[agent] = append(lines[agent], from)
+		}
+		lines[agent] = append(lines[agent], to)
+	})
+	for agent, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		col := c.palette[agent]
+		fmt.Fprintf(bw, `<polyline fill="none" stroke="#%02x%02x%02x" stroke-width="1" points="`, col.R, col.G, col.B)
+		for i, p := range line {
+			x, y := c.corner(p)
+			if i > 0 {
+				bw.WriteByte(' ')
+			}
+			fmt.Fprintf(bw, "%d,%d", x+c.scale/2, y+c.scale/2)
+		}
+		fmt.Fprintln(bw, `"/>`)
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+// walkTrail calls fn for every recorded move with the previous point of the agent,
+// the agents start where they were when the recording started
+func (c *canvas) walkTrail(mp *MultiAgentPath, fn func(agent int, from, to Point)) {
+	positions := slices.Clone(mp.trailFrom)
+	for _, s := range mp.Trail() {
+		fn(s.Agent, positions[s.Agent], s.Point)
+		positions[s.Agent] = s.Point
+	}
+}
+
+// drawLine draws a line with the Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, col color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	e := dx + dy
+	for {
+		img.SetRGBA(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if 2*e >= dy {
+			e += dy
+			x0 += sx
+		}
+		if 2*e <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
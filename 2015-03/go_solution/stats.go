@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmp"
+	"math/bits"
+	"slices"
+)
+
+// AgentSet is a set of agents, agent i is the bit i
+type AgentSet [MaxAgents / 64]uint64
+
+func (s *AgentSet) Add(agent int) {
+	s[agent/64] |= 1 << (agent % 64)
+}
+
+func (s *AgentSet) Has(agent int) bool {
+	return s[agent/64]&(1<<(agent%64)) != 0
+}
+
+// Len returns the number of agents in the set
+func (s *AgentSet) Len() int {
+	n := 0
+	for _, w := range s {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Visit is how many presents a house got and which agents delivered them
+type Visit struct {
+	Count  int
+	Agents AgentSet
+}
+
+// HouseVisit is a visited house with its statistics
+type HouseVisit struct {
+	Point Point
+	Visit
+}
+
+// Visits returns the statistics of the house, zero Visit if it is not visited
+func (ip innerPath) Visits(p Point) Visit {
+	return ip.store.Visits(p)
+}
+
+// counted returns ErrPresenceOnly if the store does not count presents
+func (ip innerPath) counted() error {
+	if !ip.store.Counts() {
+		return ErrPresenceOnly
+	}
+	return nil
+}
+
+// TopVisited returns up to n most visited houses, nil if n is not positive.
+// Houses with the same count are ordered by y and then by x.
+func (ip innerPath) TopVisited(n int) ([]HouseVisit, error) {
+	if err := ip.counted(); err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	houses := make([]HouseVisit, 0, ip.store.Len())
+	for p, v := range ip.store.All() {
+		houses = append(houses, HouseVisit{p, v})
+	}
+	slices.SortFunc(houses, func(a, b HouseVisit) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return comparePoints(a.Point, b.Point)
+	})
+	return houses[:min(n, len(houses))], nil
+}
+
+// VisitedOnce returns the houses which got exactly one present ordered by y and then by x
+func (ip innerPath) VisitedOnce() ([]Point, error) {
+	if err := ip.counted(); err != nil {
+		return nil, err
+	}
+	once := make([]Point, 0)
+	for p, v := range ip.store.All() {
+		if v.Count == 1 {
+			once = append(once, p)
+		}
+	}
+	slices.SortFunc(once, comparePoints)
+	return once, nil
+}
+
+// Histogram returns the number of houses for every count of presents: h[3] houses got exactly 3 presents
+func (ip innerPath) Histogram() ([]int, error) {
+	if err := ip.counted(); err != nil {
+		return nil, err
+	}
+	h := make([]int, 1)
+	for _, v := range ip.store.All() {
+		for len(h) <= v.Count {
+			h = append(h, 0)
+		}
+		h[v.Count]++
+	}
+	return h, nil
+}
+
+// AtLeast returns the number of houses which got at least n presents
+func (ip innerPath) AtLeast(n int) (int, error) {
+	if err := ip.counted(); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, v := range ip.store.All() {
+		if v.Count >= n {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func comparePoints(a, b Point) int {
+	if c := cmp.Compare(a.y, b.y); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.x, b.x); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.z, b.z)
+}
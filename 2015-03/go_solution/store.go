@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"iter"
+	"math/bits"
+)
+
+// ErrPresenceOnly is returned by the statistics and rendering of a path which store keeps only visited houses
+var ErrPresenceOnly = errors.New("the store does not count presents, use the map store")
+
+// VisitStore keeps the visited houses of a path
+type VisitStore interface {
+	// Visit records a present delivered by the agent to the house
+	Visit(p Point, agent int)
+	// VisitN records n presents delivered by the agent to the house
+	VisitN(p Point, agent, n int)
+	// Visits returns the statistics of the house, zero Visit if it is not visited
+	Visits(p Point) Visit
+	// Len returns the number of visited houses
+	Len() int
+	// All iterates over the visited houses in no particular order
+	All() iter.Seq2[Point, Visit]
+	// Counts reports if the visits have their counts and agents,
+	// otherwise every visited house has Count 1 without agents
+	Counts() bool
+}
+
+// VisitStores are the stores by their names for the command line
+var VisitStores = map[string]func() VisitStore{
+	"map":   func() VisitStore { return NewMapStore() },
+	"tiles": func() VisitStore { return NewTileStore() },
+}
+
+// MapStore keeps full statistics of every house in a map
+type MapStore map[Point]Visit
+
+func NewMapStore() MapStore {
+	return make(MapStore)
+}
+
+func (s MapStore) Visit(p Point, agent int) {
+	s.VisitN(p, agent, 1)
+}
+
+func (s MapStore) VisitN(p Point, agent, n int) {
+	v := s[p]
+	v.Count += n
+	v.Agents.Add(agent)
+	s[p] = v
+}
+
+func (s MapStore) Visits(p Point) Visit {
+	return s[p]
+}
+
+func (s MapStore) Len() int {
+	return len(s)
+}
+
+func (s MapStore) Counts() bool {
+	return true
+}
+
+func (s MapStore) All() iter.Seq2[Point, Visit] {
+	return func(yield func(Point, Visit) bool) {
+		for p, v := range s {
+			if !yield(p, v) {
+				return
+			}
+		}
+	}
+}
+
+const (
+	tileBits = 6
+	tileSize = 1 << tileBits
+	tileMask = tileSize - 1
+)
+
+// tile is a bitmap of tileSize x tileSize houses, bit y*tileSize+x is the house (x, y) of the tile
+type tile [tileSize * tileSize / 64]uint64
+
+// TileStore keeps only the presence of houses in bitmap tiles of 64x64 houses,
+// that is 512 bytes for a tile instead of tens of bytes for every house of MapStore.
+// It does not count presents: Visits returns Count 1 without agents for every visited house.
+type TileStore struct {
+	tiles map[Point]*tile
+	len   int
+	// paths move to the neighbour houses, so the last tile is usually the next one
+	lastKey  Point
+	lastTile *tile
+}
+
+func NewTileStore() *TileStore {
+	return &TileStore{tiles: make(map[Point]*tile)}
+}
+
+// locate returns the key of the tile of the house and the index of the house bit in it.
+// Arithmetic shift and mask work for negative coordinates as well.
+func locate(p Point) (Point, int) {
+	return Point{p.x >> tileBits, p.y >> tileBits, p.z}, (p.y&tileMask)<<tileBits | p.x&tileMask
+}
+
+func (s *TileStore) tile(key Point, create bool) *tile {
+	if s.lastTile != nil && s.lastKey == key {
+		return s.lastTile
+	}
+	t, ok := s.tiles[key]
+	if !ok {
+		if !create {
+			return nil
+		}
+		t = new(tile)
+		s.tiles[key] = t
+	}
+	s.lastKey, s.lastTile = key, t
+	return t
+}
+
+func (s *TileStore) Visit(p Point, agent int) {
+	s.VisitN(p, agent, 1)
+}
+
+// VisitN marks the house as visited, the number of presents is not kept
+func (s *TileStore) VisitN(p Point, agent, n int) {
+	key, i := locate(p)
+	t := s.tile(key, true)
+	if t[i/64]&(1<<(i%64)) == 0 {
+		t[i/64] |= 1 << (i % 64)
+		s.len++
+	}
+}
+
+func (s *TileStore) Visits(p Point) Visit {
+	key, i := locate(p)
+	t := s.tile(key, false)
+	if t == nil || t[i/64]&(1<<(i%64)) == 0 {
+		return Visit{}
+	}
+	return Visit{Count: 1}
+}
+
+func (s *TileStore) Len() int {
+	return s.len
+}
+
+func (s *TileStore) Counts() bool {
+	return false
+}
+
+func (s *TileStore) All() iter.Seq2[Point, Visit] {
+	return func(yield func(Point, Visit) bool) {
+		for key, t := range s.tiles {
+			for w, word := range t {
+				for word != 0 {
+					i := w*64 + bits.TrailingZeros64(word)
+					word &= word - 1
+					p := Point{key.x<<tileBits | i&tileMask, key.y<<tileBits | i>>tileBits, key.z}
+					if !yield(p, Visit{Count: 1}) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+	"time"
+)
+
+// agentSymbols mark the current positions of agents on the grid, '@' is used for the rest of them
+const agentSymbols = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// RenderGrid draws the houses around the current positions of the agents, north is at the top.
+// An agent is shown by its letter, a visited house by the number of its presents
+// ('+' for more than 9) and an empty house by '.'.
+// Cube grids are shown by the floor of the first agent.
+func RenderGrid(w io.Writer, mp *MultiAgentPath, radius int) error {
+	if err := mp.counted(); err != nil {
+		return err
+	}
+	positions := mp.Positions()
+	minP, maxP := positions[0], positions[0]
+	for _, p := range positions {
+		minP.x, minP.y = min(minP.x, p.x), min(minP.y, p.y)
+		maxP.x, maxP.y = max(maxP.x, p.x), max(maxP.y, p.y)
+	}
+	z := positions[0].z
+	agents := make(map[Point]byte, len(positions))
+	// the first agent is on top when several of them share a house
+	for a := len(positions) - 1; a >= 0; a-- {
+		agents[positions[a]] = agentSymbol(a)
+	}
+
+	var sb strings.Builder
+	for y := maxP.y + radius; y >= minP.y-radius; y-- {
+		for x := minP.x - radius; x <= maxP.x+radius; x++ {
+			p := Point{x, y, z}
+			if s, ok := agents[p]; ok {
+				sb.WriteByte(s)
+				continue
+			}
+			switch c := mp.Visits(p).Count; {
+			case c == 0:
+				sb.WriteByte('.')
+			case c > 9:
+				sb.WriteByte('+')
+			default:
+				sb.WriteByte(byte('0' + c))
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func agentSymbol(agent int) byte {
+	if agent < len(agentSymbols) {
+		return agentSymbols[agent]
+	}
+	return '@'
+}
+
+// ReplayOptions are the options of Replay
+type ReplayOptions struct {
+	// Radius is the number of houses shown around the agents
+	Radius int
+	// Delay is the pause after every frame
+	Delay time.Duration
+	// Step waits for a line from the input of Replay after every frame instead of the delay
+	Step bool
+	// Clear clears the terminal before every frame
+	Clear bool
+	// Directions reads run-length instructions of the table instead of the symbols of the grid
+	Directions Directions
+	// Lenient skips bad symbols, like the trailing newline, without a frame
+	Lenient bool
+}
+
+// replayMove is a single move of the replay with the symbol it is read from
+type replayMove struct {
+	symbol string
+	delta  Point
+}
+
+// replayMoves returns the moves of r one by one, a repeated instruction is several moves
+func replayMoves(r io.Reader, coords CoordSystem, opts ReplayOptions) iter.Seq2[replayMove, error] {
+	if opts.Directions != nil {
+		return func(yield func(replayMove, error) bool) {
+			ir := NewInstructionReader(r, opts.Directions)
+			ir.Lenient = opts.Lenient
+			for {
+				in, err := ir.Next()
+				if errors.Is(err, io.EOF) {
+					return
+				}
+				if err != nil {
+					yield(replayMove{}, err)
+					return
+				}
+				for i := 0; i < in.Count; i++ {
+					if !yield(replayMove{in.Symbol, in.Delta}, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+	return func(yield func(replayMove, error) bool) {
+		bufReader := bufio.NewReader(r)
+		var offset int64
+		for {
+			d, size, err := bufReader.ReadRune()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(replayMove{}, err)
+				return
+			}
+			delta, err := coords.Delta(d)
+			var se *SymbolError
+			switch {
+			case opts.Lenient && errors.As(err, &se):
+			case err != nil:
+				yield(replayMove{}, atOffset(err, offset))
+				return
+			case !yield(replayMove{string(d), delta}, nil):
+				return
+			}
+			offset += int64(size)
+		}
+	}
+}
+
+// Replay moves the path by every instruction of r and draws a frame after every move
+// with the positions of the agents. Lines of input are read only in step mode.
+func Replay(r io.Reader, w io.Writer, input io.Reader, mp *MultiAgentPath, opts ReplayOptions) error {
+	var inputReader *bufio.Reader
+	if opts.Step {
+		inputReader = bufio.NewReader(input)
+	}
+
+	bw := bufio.NewWriter(w)
+	step := 0
+	for m, err := range replayMoves(r, mp.Coords(), opts) {
+		step++
+		if err != nil {
+			return fmt.Errorf("step %d: %w", step, err)
+		}
+		agent := mp.Next()
+		err = mp.MoveBy(m.delta)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", step, err)
+		}
+
+		if opts.Clear {
+			bw.WriteString("\x1b[H\x1b[2J")
+		}
+		fmt.Fprintf(bw, "step %d: %s moves %c, houses %d\n", step, m.symbol, agentSymbol(agent), mp.Len())
+		for a, p := range mp.Positions() {
+			if _, ok := mp.Coords().(Cube); ok {
+				fmt.Fprintf(bw, "%c (%d, %d, %d)\n", agentSymbol(a), p.x, p.y, p.z)
+				continue
+			}
+			fmt.Fprintf(bw, "%c (%d, %d)\n", agentSymbol(a), p.x, p.y)
+		}
+		err = RenderGrid(bw, mp, opts.Radius)
+		if err != nil {
+			return err
+		}
+		err = bw.Flush()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case opts.Step:
+			_, err = inputReader.ReadString('\n')
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		case opts.Delay > 0:
+			time.Sleep(opts.Delay)
+		}
+	}
+	return nil
+}
+
+// ReplayFile replays the Santa and Robot path of input.txt on the terminal
+func ReplayFile(opts ReplayOptions) error {
+	file, err := os.Open("input.txt")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return Replay(file, os.Stdout, os.Stdin, NewSantaRobotPath(), opts)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint is the state of a search saved to continue it later
+type Checkpoint struct {
+	Secret    string `json:"secret"`
+	Algo      string `json:"algo"`
+	Predicate string `json:"predicate"`
+	Searched  uint   `json:"searched"` // all suffixes below are searched
+}
+
+// LoadCheckpoint reads a checkpoint file
+func LoadCheckpoint(name string) (*Checkpoint, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	err = json.Unmarshal(data, &cp)
+	if err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// Save writes the checkpoint file. The file is replaced at once,
+// so an interrupted save does not destroy the previous checkpoint.
+func (cp *Checkpoint) Save(name string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// SameSearch checks if the checkpoint belongs to the same search
+func (cp *Checkpoint) SameSearch(other *Checkpoint) bool {
+	return cp.Secret == other.Secret && cp.Algo == other.Algo && cp.Predicate == other.Predicate
+}
+
+// SolveWithCheckpoints runs the miner and saves the checkpoint file every interval
+// and once more when the search is stopped by ctx.
+// A failed save stops the search, unless the suffix is already found.
+func SolveWithCheckpoints(ctx context.Context, m *Miner, cp Checkpoint, name string, interval time.Duration) (uint, error) {
+	if interval <= 0 {
+		return 0, fmt.Errorf("checkpoint interval %v is not positive", interval)
+	}
+	solveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	saved := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				saved <- nil
+				return
+			case <-ticker.C:
+				cp.Searched = m.Searched()
+				if err := cp.Save(name); err != nil {
+					saved <- err
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	s, err := m.Solve(solveCtx)
+	close(done)
+	saveErr := <-saved
+	if err == nil {
+		return s, nil
+	}
+	if saveErr != nil && ctx.Err() == nil {
+		return 0, fmt.Errorf("search is stopped: cannot save checkpoint: %w", saveErr)
+	}
+	if ctx.Err() != nil {
+		// the final checkpoint after SIGINT or timeout
+		cp.Searched = m.Searched()
+		if saveErr := cp.Save(name); saveErr != nil {
+			return 0, saveErr
+		}
+	}
+	return s, err
+}
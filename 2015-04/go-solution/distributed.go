@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/rpc"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// distributedRangeSize is the default number of suffixes handed to a worker process at once
+const distributedRangeSize uint = 1 << 20
+
+// Job describes the search for remote workers. Predicate is a name for ParsePredicate.
+type Job struct {
+	Secret    string
+	Algo      string
+	Predicate string
+}
+
+// NewMiner builds a miner of the job
+func (j Job) NewMiner() (*Miner, error) {
+	newHash, ok := Algorithms[j.Algo]
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm %s", j.Algo)
+	}
+	check, err := ParsePredicate(j.Predicate)
+	if err != nil {
+		return nil, err
+	}
+	return &Miner{Secret: j.Secret, Check: check, NewHash: newHash}, nil
+}
+
+type ClaimArgs struct{}
+
+// ClaimReply is a range to search. An empty range means there is no work right now, but the search is not done.
+type ClaimReply struct {
+	Job      Job
+	From, To uint
+	Done     bool
+}
+
+type ReportArgs struct {
+	From, To uint
+	Found    bool
+	Suffix   uint
+	Hashes   uint64
+}
+
+type ReportReply struct {
+	Done bool
+}
+
+type suffixRange struct {
+	from, to uint
+}
+
+// Coordinator hands out suffix ranges to worker processes over net/rpc and collects their results.
+// Ranges of a disconnected worker are handed out again before new ones.
+type Coordinator struct {
+	job       Job
+	rangeSize uint
+	linger    time.Duration
+
+	mu       sync.Mutex
+	next     uint
+	released []suffixRange
+	claimed  map[uint]int // range start -> session
+	sessions int
+	searched atomic.Uint64
+	hashed   atomic.Uint64
+	pool     *rangePool
+	solved   chan struct{}
+}
+
+// NewCoordinator creates a coordinator of the job searching from the start suffix
+func NewCoordinator(job Job, start uint) *Coordinator {
+	c := &Coordinator{
+		job:       job,
+		rangeSize: distributedRangeSize,
+		linger:    2 * time.Second,
+		next:      start,
+		claimed:   make(map[uint]int),
+		solved:    make(chan struct{}),
+	}
+	c.pool = newRangePool(start, &c.searched)
+	return c
+}
+
+// Serve accepts workers until the lowest suffix is found or ctx is done.
+// After the search is solved, connected workers have the linger time to learn it before their connections are closed.
+func (c *Coordinator) Serve(ctx context.Context, ln net.Listener) (uint, error) {
+	var wg sync.WaitGroup
+	var connsMu sync.Mutex
+	conns := make(map[net.Conn]struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			connsMu.Lock()
+			conns[conn] = struct{}{}
+			connsMu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.serveConn(conn)
+				connsMu.Lock()
+				delete(conns, conn)
+				connsMu.Unlock()
+			}()
+		}
+	}()
+
+	var err error
+	select {
+	case <-c.solved:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	ln.Close()
+
+	// let the workers ask for the next range and leave by themselves
+	if err == nil {
+		deadline := time.Now().Add(c.linger)
+		for time.Now().Before(deadline) {
+			connsMu.Lock()
+			left := len(conns)
+			connsMu.Unlock()
+			if left == 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	connsMu.Lock()
+	for conn := range conns {
+		conn.Close()
+	}
+	connsMu.Unlock()
+	wg.Wait()
+
+	if err != nil {
+		return 0, err
+	}
+	s, _ := c.pool.result()
+	return s, nil
+}
+
+// Searched returns the suffix below which all suffixes are searched by the workers
+func (c *Coordinator) Searched() uint {
+	return uint(c.searched.Load())
+}
+
+// Hashes returns the number of hashes tried by the workers in the reported ranges
+func (c *Coordinator) Hashes() uint64 {
+	return c.hashed.Load()
+}
+
+func (c *Coordinator) serveConn(conn net.Conn) {
+	c.mu.Lock()
+	c.sessions++
+	id := c.sessions
+	c.mu.Unlock()
+
+	srv := rpc.NewServer()
+	srv.RegisterName("Coordinator", &coordinatorSession{c, id})
+	srv.ServeConn(conn)
+	c.release(id)
+}
+
+func (c *Coordinator) isSolved() bool {
+	select {
+	case <-c.solved:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Coordinator) claim(session int) ClaimReply {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reply := ClaimReply{Job: c.job, Done: c.isSolved()}
+	if reply.Done {
+		return reply
+	}
+	best, _ := c.pool.result()
+
+	var r suffixRange
+	switch {
+	case len(c.released) > 0:
+		r, c.released = c.released[0], c.released[1:]
+	case c.next < best && c.next < math.MaxUint:
+		r = suffixRange{c.next, c.next + min(c.rangeSize, math.MaxUint-c.next)}
+		c.next = r.to
+	default:
+		// the rest of the ranges are searched by other workers
+		return reply
+	}
+	c.claimed[r.from] = session
+	reply.From, reply.To = r.from, r.to
+	return reply
+}
+
+func (c *Coordinator) report(args ReportArgs) ReportReply {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.claimed[args.From]; ok && !c.isSolved() {
+		delete(c.claimed, args.From)
+		c.hashed.Add(args.Hashes)
+		if args.Found {
+			c.pool.report(args.Suffix)
+		}
+		c.pool.complete(args.From, args.To)
+		// solved when every range below the lowest found suffix is searched
+		if best, ok := c.pool.result(); ok && c.Searched() > best {
+			close(c.solved)
+		}
+	}
+	return ReportReply{Done: c.isSolved()}
+}
+
+// release returns the unfinished ranges of the session for other workers
+func (c *Coordinator) release(session int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for from, s := range c.claimed {
+		if s != session {
+			continue
+		}
+		delete(c.claimed, from)
+		to := uint(math.MaxUint)
+		if from <= math.MaxUint-c.rangeSize {
+			to = from + c.rangeSize
+		}
+		c.released = append(c.released, suffixRange{from, to})
+	}
+	slices.SortFunc(c.released, func(a, b suffixRange) int { return compareUint(a.from, b.from) })
+}
+
+func compareUint(a, b uint) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// coordinatorSession is the RPC service of a single worker connection
+type coordinatorSession struct {
+	c  *Coordinator
+	id int
+}
+
+func (s *coordinatorSession) Claim(args ClaimArgs, reply *ClaimReply) error {
+	*reply = s.c.claim(s.id)
+	return nil
+}
+
+func (s *coordinatorSession) Report(args ReportArgs, reply *ReportReply) error {
+	*reply = s.c.report(args)
+	return nil
+}
+
+// RunWorker connects to the coordinator and searches its ranges with the given number of goroutines.
+// It returns nil when the coordinator reports that the search is done.
+func RunWorker(ctx context.Context, network, addr string, workers int) error {
+	client, err := rpc.Dial(network, addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	errs := make(chan error, max(workers, 1))
+	for i := 0; i < max(workers, 1); i++ {
+		go func() {
+			errs <- workRanges(ctx, client)
+		}()
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			// stop the other goroutines
+			client.Close()
+		}
+	}
+	if errors.Is(err, rpc.ErrShutdown) && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// workRanges claims and searches ranges until the search is done
+func workRanges(ctx context.Context, client *rpc.Client) error {
+	var m *Miner
+	var search func(from, to uint) (uint, bool)
+	for ctx.Err() == nil {
+		var claim ClaimReply
+		err := client.Call("Coordinator.Claim", ClaimArgs{}, &claim)
+		if err != nil {
+			return err
+		}
+		if claim.Done {
+			return nil
+		}
+		if claim.From == claim.To {
+			select {
+			case <-ctx.Done():
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+
+		if m == nil {
+			m, err = claim.Job.NewMiner()
+			if err != nil {
+				return err
+			}
+			search = m.searcher()
+		}
+		hashed := m.Hashes()
+		s, found := search(claim.From, claim.To)
+
+		var reply ReportReply
+		args := ReportArgs{claim.From, claim.To, found, s, m.Hashes() - hashed}
+		err = client.Call("Coordinator.Report", args, &reply)
+		if err != nil {
+			return err
+		}
+		if reply.Done {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
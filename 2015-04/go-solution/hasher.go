@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding"
+	"hash"
+	"strconv"
+)
+
+// hasher calculates digests of the secret with decimal suffixes without allocations.
+// If the hash implements encoding.BinaryMarshaler, the state after the secret is calculated once
+// and restored for every suffix instead of hashing the secret again.
+type hasher struct {
+	h      hash.Hash
+	secret string
+	state  []byte // the state after the secret, nil if the hash cannot be marshaled
+	buf    []byte
+	sum    []byte
+}
+
+func newHasher(newHash func() hash.Hash, secret string) *hasher {
+	hs := &hasher{h: newHash(), secret: secret}
+	m, okM := hs.h.(encoding.BinaryMarshaler)
+	_, okU := hs.h.(encoding.BinaryUnmarshaler)
+	if okM && okU {
+		hs.h.Write([]byte(secret))
+		state, err := m.MarshalBinary()
+		if err == nil {
+			hs.state = state
+		}
+	}
+	return hs
+}
+
+// digest returns the digest of the secret with the suffix.
+// The returned slice is reused by the next call.
+func (hs *hasher) digest(suffix uint) []byte {
+	if hs.state != nil {
+		// the state has been produced by the same hash, so it is always valid
+		hs.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(hs.state)
+		hs.buf = strconv.AppendUint(hs.buf[:0], uint64(suffix), 10)
+	} else {
+		hs.h.Reset()
+		hs.buf = strconv.AppendUint(append(hs.buf[:0], hs.secret...), uint64(suffix), 10)
+	}
+	hs.h.Write(hs.buf)
+	hs.sum = hs.h.Sum(hs.sum[:0])
+	return hs.sum
+}
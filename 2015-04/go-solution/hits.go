@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"math"
+	"slices"
+	"sync"
+)
+
+// Hit is a suffix which digest passes the check
+type Hit struct {
+	Suffix uint
+	Digest []byte
+}
+
+// rangeHits is the result of searching a whole range
+type rangeHits struct {
+	from, to uint
+	hits     []Hit
+}
+
+// All returns an iterator over all passing suffixes from Start in ascending order.
+// It stops after limit hits if limit is not 0, before the suffix end if end is not 0, or
+// when ctx is done yielding ctx.Err(). Workers search ranges ahead of the consumer, but
+// hold no more than a few ranges per worker, and are finished when the iteration stops.
+func (m *Miner) All(ctx context.Context, limit int, end uint) iter.Seq2[Hit, error] {
+	if end == 0 {
+		end = math.MaxUint
+	}
+	return func(yield func(Hit, error) bool) {
+		m.hashed.Store(0)
+		m.searched.Store(uint64(m.Start))
+		if m.OnProgress != nil {
+			stop := m.reportProgress()
+			defer stop()
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		results := m.rangeResults(ctx, end)
+		defer func() {
+			// stop the workers and wait until they are finished
+			cancel()
+			for range results {
+			}
+		}()
+
+		count := 0
+		for r := range results {
+			for _, h := range r.hits {
+				if !yield(h, nil) {
+					return
+				}
+				count++
+				if limit > 0 && count >= limit {
+					return
+				}
+			}
+			m.searched.Store(uint64(r.to))
+		}
+		if err := ctx.Err(); err != nil {
+			yield(Hit{}, err)
+		}
+	}
+}
+
+// rangeResults searches ranges from Start to end and sends their results in ascending order.
+// The channel is closed when all ranges are sent or ctx is done.
+func (m *Miner) rangeResults(ctx context.Context, end uint) <-chan rangeHits {
+	workers := m.workerCount()
+	window := 4 * workers
+	ordered := make(chan rangeHits)
+
+	// the producer claims the ranges in order and hands them to workers,
+	// tokens limit the ranges which are searched but not consumed yet
+	tokens := make(chan struct{}, window)
+	claims := make(chan uint)
+	found := make(chan rangeHits, window)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hs := m.newHasher()
+			for from := range claims {
+				to := min(rangeEnd(from), end)
+				found <- rangeHits{from, to, m.rangeHits(hs, from, to)}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(claims)
+		for from := m.Start; from < end; from = min(rangeEnd(from), end) {
+			select {
+			case <-ctx.Done():
+				return
+			case tokens <- struct{}{}:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case claims <- from:
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	// reorder the results, the next range to send starts at next
+	go func() {
+		defer close(ordered)
+		pending := make(map[uint]rangeHits)
+		next := m.Start
+		for r := range found {
+			pending[r.from] = r
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				select {
+				case <-ctx.Done():
+					// let the workers finish and leave
+					for range found {
+					}
+					return
+				case ordered <- r:
+				}
+				<-tokens
+				next = r.to
+			}
+		}
+	}()
+	return ordered
+}
+
+// rangeHits returns all passing suffixes in [from, to)
+func (m *Miner) rangeHits(hs *hasher, from, to uint) []Hit {
+	var hits []Hit
+	for i := from; i < to; i++ {
+		digest := hs.digest(i)
+		if m.Check(digest) {
+			hits = append(hits, Hit{i, slices.Clone(digest)})
+		}
+	}
+	m.hashed.Add(uint64(to - from))
+	return hits
+}
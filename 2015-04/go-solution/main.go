@@ -1,18 +1,34 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"math"
+	"io"
+	"maps"
+	"net"
+	"net/http"
 	"os"
-	"sync"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
 )
 
-const WORKER_COUNT int = 6
+// batchSize is how many consecutive suffixes are searched between checks of the context.
+// Workers claim ranges of this size.
+const batchSize uint = 1 << 14
 
 type Md5Hash = [16]byte
 
+var errNotFound = errors.New("cannot find the number: it is bigger than uint")
+
 func CalcHash(input string, suffix uint) Md5Hash {
 	str := fmt.Sprintf("%s%d", input, suffix)
 	return md5.Sum([]byte(str))
@@ -28,84 +44,353 @@ func CheckPrefix6(h Md5Hash) bool {
 	return h[0] == 0x0 && h[1] == 0x0 && h[2] == 0x0
 }
 
-func Worker(wg *sync.WaitGroup, input string, hCheck func(Md5Hash) bool, suffix <-chan uint, result chan<- uint) {
-	for s := range suffix {
-		h := CalcHash(input, s)
-		if hCheck(h) {
-			result <- s
+// SolveWithWorkers solves the problem with runtime.NumCPU() workers
+func SolveWithWorkers(input string, hCheck func(Md5Hash) bool) (uint, error) {
+	return SolveWithWorkersContext(context.Background(), input, hCheck)
+}
+
+// SolveWithWorkersContext is SolveWithWorkers which stops when ctx is done.
+// All workers are finished when it returns.
+func SolveWithWorkersContext(ctx context.Context, input string, hCheck func(Md5Hash) bool) (uint, error) {
+	return SolveWithWorkerCount(ctx, input, hCheck, runtime.NumCPU())
+}
+
+// SolveWithWorkerCount solves the problem with the given number of workers.
+// Every worker claims a range of batchSize suffixes at a time, so the workers do not synchronise per hash.
+// It returns the minimal suffix like Solve does.
+func SolveWithWorkerCount(ctx context.Context, input string, hCheck func(Md5Hash) bool, workers int) (uint, error) {
+	m := Miner{Secret: input, Check: Md5Predicate(hCheck), Workers: max(workers, 1)}
+	return m.Solve(ctx)
+}
+
+func Solve(input string, hCheck func(Md5Hash) bool) (uint, error) {
+	return SolveContext(context.Background(), input, hCheck)
+}
+
+// SolveContext is Solve which stops when ctx is done and returns ctx.Err()
+func SolveContext(ctx context.Context, input string, hCheck func(Md5Hash) bool) (uint, error) {
+	m := Miner{Secret: input, Check: Md5Predicate(hCheck), Workers: 1}
+	return m.Solve(ctx)
+}
+
+// difficulty is a check with its name, the name identifies the search in checkpoints
+type difficulty struct {
+	name  string
+	check Predicate
+}
+
+// checksFromFlags returns the checks for the requested difficulty and a digest of the given size.
+// Without flags the both parts of the problem are solved: 5 and 6 zeros.
+func checksFromFlags(zeros, bits int, prefix string, digestSize int) ([]difficulty, error) {
+	set := 0
+	for _, isSet := range []bool{zeros != 0, bits != 0, prefix != ""} {
+		if isSet {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return nil, errors.New("only one of -zeros, -bits and -prefix can be set")
+	case zeros < 0 || zeros > 2*digestSize:
+		return nil, fmt.Errorf("-zeros must be between 0 (not set) and %d", 2*digestSize)
+	case bits < 0 || bits > 8*digestSize:
+		return nil, fmt.Errorf("-bits must be between 0 (not set) and %d", 8*digestSize)
+	case len(prefix) > 2*digestSize:
+		return nil, fmt.Errorf("-prefix cannot be longer than %d", 2*digestSize)
+	case zeros != 0:
+		return []difficulty{{fmt.Sprintf("zeros=%d", zeros), LeadingZeroNibbles(zeros)}}, nil
+	case bits != 0:
+		return []difficulty{{fmt.Sprintf("bits=%d", bits), LeadingZeroBits(bits)}}, nil
+	case prefix != "":
+		check, err := HexPrefix(prefix)
+		if err != nil {
+			return nil, err
 		}
-		wg.Done()
+		return []difficulty{{"prefix=" + strings.ToLower(prefix), check}}, nil
 	}
+	return []difficulty{{"zeros=5", LeadingZeroNibbles(5)}, {"zeros=6", LeadingZeroNibbles(6)}}, nil
 }
 
-// SolveWithWorkers solving hashes using workers. Unfortunatelly, it is not effective.
-// Md5 hash calc is too fast and it takes more time than sinle thread solution
-func SolveWithWorkers(input string, hCheck func(Md5Hash) bool) (uint, error) {
-	var wg sync.WaitGroup
-	suffix := make(chan uint, WORKER_COUNT)
-	result := make(chan uint, WORKER_COUNT)
-
-	// Create workers
-	for i := 0; i < WORKER_COUNT; i++ {
-		go Worker(&wg, input, hCheck, suffix, result)
-	}
-
-	var i uint
-	for {
-		// Send numbers to workers
-		for j := 0; j < WORKER_COUNT; j++ {
-			if i == math.MaxUint {
-				// hope I won't wait for this :)
-				return 0, errors.New("cannot find the number: it is bigger than uint")
-			}
-			wg.Add(1)
-			suffix <- i
-			i++
-		}
-
-		// Wait until all workers are done in this cycle
-		wg.Wait()
-
-		// if we have result we should fetch it
-		// theoreticaly we can have WORKER_COUNT results, so we should take the minimum
-		if len(result) > 0 {
-			var min uint = math.MaxUint
-			for len(result) > 0 {
-				r := <-result
-				if r < min {
-					min = r
-				}
-			}
-			return min, nil
+// defaultSecret is the puzzle input used when no secret is given
+const defaultSecret = "bgvyzdsv"
+
+// readSecret returns the secret from the flag or from the file, - reads it from stdin.
+// Surrounding whitespace is trimmed, so the puzzle input can be saved as is.
+// Without both the default secret is returned.
+func readSecret(secret, file string, stdin io.Reader) (string, error) {
+	if secret != "" && file != "" {
+		return "", errors.New("only one of -secret and -secret-file can be set")
+	}
+	if secret == "" && file == "" {
+		return defaultSecret, nil
+	}
+	if file != "" {
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(file)
 		}
+		if err != nil {
+			return "", err
+		}
+		secret = string(data)
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", errors.New("the secret is empty")
 	}
+	return secret, nil
 }
 
-func Solve(input string, hCheck func(Md5Hash) bool) (uint, error) {
-	for i := uint(0); i < math.MaxUint; i++ {
-		h := CalcHash(input, i)
-		if hCheck(h) {
-			return i, nil
+// Result is a found suffix as it is printed with -json
+type Result struct {
+	Secret     string        `json:"secret"`
+	Difficulty string        `json:"difficulty"`
+	Suffix     uint          `json:"suffix"`
+	Digest     string        `json:"digest"`
+	Elapsed    time.Duration `json:"elapsed_ns"`
+	Hashes     uint64        `json:"hashes"`
+}
+
+// printResult prints the result as a JSON line or as text
+func printResult(w io.Writer, r Result, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(r)
+	}
+	_, err := fmt.Fprintf(w, "For \"%s\" %s solution %d, digest %s, %d hashes in %v\n",
+		r.Secret, r.Difficulty, r.Suffix, r.Digest, r.Hashes, r.Elapsed.Round(time.Millisecond))
+	return err
+}
+
+func run() error {
+	secret := flag.String("secret", "", "secret key of the puzzle (default \""+defaultSecret+"\")")
+	secretFile := flag.String("secret-file", "", "read the secret from the file, - for stdin")
+	zeros := flag.Int("zeros", 0, "number of leading zero hex digits")
+	bits := flag.Int("bits", 0, "number of leading zero bits")
+	prefix := flag.String("prefix", "", "hex prefix of the hash, e.g. abc")
+	algo := flag.String("algo", "md5", "hash algorithm: "+strings.Join(slices.Sorted(maps.Keys(Algorithms)), ", "))
+	start := flag.Uint("start", 0, "first suffix to search")
+	workers := flag.Int("workers", 0, "number of workers in parallel and worker modes, 0 for the number of CPUs")
+	mode := flag.String("mode", "parallel", "single, parallel, coordinator (hands out ranges to worker processes) or worker")
+	asJSON := flag.Bool("json", false, "print results as JSON lines")
+	checkpoint := flag.String("checkpoint", "", "file to save the search state periodically and on interrupt")
+	cpInterval := flag.Duration("checkpoint-interval", time.Minute, "how often the checkpoint is saved")
+	resume := flag.Bool("resume", false, "continue the search from the -checkpoint file")
+	progress := flag.Duration("progress", 0, "print the progress line to stderr with the interval, 0 to disable")
+	metrics := flag.String("metrics", "", "serve /metrics and /debug/vars on the address, e.g. localhost:9104")
+	count := flag.Int("count", 0, "print the first count passing suffixes with digests instead of the lowest one")
+	end := flag.Uint("end", 0, "print all passing suffixes below end with digests instead of the lowest one")
+	network := flag.String("network", "tcp", "network of the coordinator: tcp or unix")
+	addr := flag.String("addr", "localhost:9107", "address of the coordinator")
+	flag.Parse()
+
+	if *workers < 0 {
+		return errors.New("-workers cannot be negative")
+	}
+	switch *mode {
+	case "single":
+		*workers = 1
+	case "parallel", "coordinator":
+	case "worker":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if *workers == 0 {
+			*workers = runtime.NumCPU()
 		}
+		return RunWorker(ctx, *network, *addr, *workers)
+	default:
+		return fmt.Errorf("unknown mode %s", *mode)
+	}
 
+	str, err := readSecret(*secret, *secretFile, os.Stdin)
+	if err != nil {
+		return err
+	}
+	newHash, ok := Algorithms[*algo]
+	if !ok {
+		return fmt.Errorf("unknown algorithm %s", *algo)
+	}
+	checks, err := checksFromFlags(*zeros, *bits, *prefix, newHash().Size())
+	if err != nil {
+		return err
+	}
+	if *resume && *checkpoint == "" {
+		return errors.New("-resume requires -checkpoint")
+	}
+	if *checkpoint != "" && *cpInterval <= 0 {
+		return errors.New("-checkpoint-interval must be positive")
+	}
+	listing := *count > 0 || *end > 0
+	if listing && *checkpoint != "" {
+		return errors.New("-checkpoint cannot be used with -count and -end")
 	}
+	if *mode == "coordinator" && (len(checks) != 1 || listing || *checkpoint != "") {
+		return errors.New("coordinator needs a single difficulty and cannot be used with -count, -end and -checkpoint")
+	}
+	var saved *Checkpoint
+	if *resume {
+		saved, err = LoadCheckpoint(*checkpoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *mode == "coordinator" {
+		ln, err := net.Listen(*network, *addr)
+		if err != nil {
+			return err
+		}
+		started := time.Now()
+		c := NewCoordinator(Job{Secret: str, Algo: *algo, Predicate: checks[0].name}, *start)
+		s, err := c.Serve(ctx, ln)
+		if err != nil {
+			return fmt.Errorf("%w: searched below %d", err, c.Searched())
+		}
+		return printResult(os.Stdout, Result{
+			Secret:     str,
+			Difficulty: checks[0].name,
+			Suffix:     s,
+			Digest:     hex.EncodeToString(CalcDigest(newHash, str, s)),
+			Elapsed:    time.Since(started),
+			Hashes:     c.Hashes(),
+		}, *asJSON)
+	}
+
+	reporters := make([]func(Progress), 0)
+	if *progress > 0 {
+		reporters = append(reporters, ProgressLine(os.Stderr))
+	}
+	if *metrics != "" {
+		pm := &ProgressMetrics{}
+		pm.Publish("miner")
+		reporters = append(reporters, pm.Update)
+
+		srv := &http.Server{Addr: *metrics, Handler: pm.Handler()}
+		ln, err := listenLoopback(*metrics)
+		if err != nil {
+			return err
+		}
+		go srv.Serve(ln)
+		defer srv.Close()
+	}
+	interval := *progress
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	for _, d := range checks {
+		m := Miner{
+			Secret:           str,
+			Check:            d.check,
+			NewHash:          newHash,
+			Workers:          *workers,
+			Start:            *start,
+			ProgressInterval: interval,
+		}
+		if len(reporters) > 0 {
+			m.OnProgress = func(p Progress) {
+				for _, report := range reporters {
+					report(p)
+				}
+			}
+		}
+		if listing {
+			err = printHits(ctx, os.Stdout, &m, d.name, *count, *end, *asJSON, len(checks) > 1)
+			if err != nil {
+				return err
+			}
+			continue
+		}
 
-	return 0, errors.New("cannot find the number: it is bigger than uint")
+		started := time.Now()
+		var s uint
+		if *checkpoint == "" {
+			s, err = m.Solve(ctx)
+		} else {
+			cp := Checkpoint{Secret: str, Algo: *algo, Predicate: d.name}
+			if saved != nil && saved.SameSearch(&cp) {
+				m.Start = max(m.Start, saved.Searched)
+			}
+			s, err = SolveWithCheckpoints(ctx, &m, cp, *checkpoint, *cpInterval)
+		}
+		if err != nil {
+			if ctx.Err() != nil && *checkpoint != "" {
+				return fmt.Errorf("%w: searched below %d, checkpoint is saved to %s", err, m.Searched(), *checkpoint)
+			}
+			return err
+		}
+		if *progress > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+		err = printResult(os.Stdout, Result{
+			Secret:     str,
+			Difficulty: d.name,
+			Suffix:     s,
+			Digest:     hex.EncodeToString(CalcDigest(newHash, str, s)),
+			Elapsed:    time.Since(started),
+			Hashes:     m.Hashes(),
+		}, *asJSON)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func main() {
-	str := "bgvyzdsv"
-	s, err := SolveWithWorkers(str, CheckPrefix5)
+// listenLoopback listens on the address only if it is a loopback one,
+// so /debug/vars is not exposed to the network
+func listenLoopback(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v", err)
-		os.Exit(-1)
+		return nil, err
 	}
-	fmt.Printf("For \"%s\" solution %d\n", str, s)
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("metrics address %s is not a loopback one, use localhost or 127.0.0.1", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// printHits prints passing suffixes in ascending order with their digests in hex.
+// With header the text output starts with the difficulty, so several lists can be told apart.
+func printHits(ctx context.Context, out io.Writer, m *Miner, difficulty string, count int, end uint, asJSON, header bool) error {
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+	if header && !asJSON {
+		fmt.Fprintf(w, "difficulty %s:\n", difficulty)
+	}
+	started := time.Now()
+	for h, err := range m.All(ctx, count, end) {
+		if err != nil {
+			return err
+		}
+		if !asJSON {
+			fmt.Fprintf(w, "%d %x\n", h.Suffix, h.Digest)
+			continue
+		}
+		err = printResult(w, Result{
+			Secret:     m.Secret,
+			Difficulty: difficulty,
+			Suffix:     h.Suffix,
+			Digest:     hex.EncodeToString(h.Digest),
+			Elapsed:    time.Since(started),
+			Hashes:     m.Hashes(),
+		}, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	s, err = SolveWithWorkers(str, CheckPrefix6)
+func main() {
+	err := run()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v", err)
 		os.Exit(-1)
 	}
-	fmt.Printf("For \"%s\" solution %d\n", str, s)
 }
@@ -1,7 +1,640 @@
 package main
 
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http/httptest"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
-import "testing"
+func TestSolve(t *testing.T) {
+	// examples from the problem statement
+	testCases := []struct {
+		input string
+		want  uint
+	}{
+		{"abcdef", 609043},
+		{"pqrstuv", 1048970},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := Solve(tc.input, CheckPrefix5)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %d, got: %d", tc.want, got)
+			}
+			for _, workers := range []int{1, 3, 8} {
+				got, err = SolveWithWorkerCount(context.Background(), tc.input, CheckPrefix5, workers)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tc.want {
+					t.Errorf("%d workers want: %d, got: %d", workers, tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func never(Md5Hash) bool { return false }
+
+func TestSolveWithWorkerCountMinimal(t *testing.T) {
+	// about every 256th hash passes, so all workers find something and only the lowest is correct
+	check := func(h Md5Hash) bool { return h[0] == 0 }
+	want, err := Solve("abcdef", check)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, workers := range []int{2, 5, 16} {
+		got, err := SolveWithWorkerCount(context.Background(), "abcdef", check, workers)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("%d workers want: %d, got: %d", workers, want, got)
+		}
+	}
+}
+
+func TestSolveContextCancel(t *testing.T) {
+	solvers := map[string]func(context.Context, string, func(Md5Hash) bool) (uint, error){
+		"single":  SolveContext,
+		"workers": SolveWithWorkersContext,
+	}
+	for name, solve := range solvers {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := solve(ctx, "bgvyzdsv", never)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("want: %v, got: %v", context.DeadlineExceeded, err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("solver stopped too late: %v", elapsed)
+			}
+
+			ctx, cancel = context.WithCancel(context.Background())
+			cancel()
+			_, err = solve(ctx, "bgvyzdsv", never)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("want: %v, got: %v", context.Canceled, err)
+			}
+
+			// no goroutines are left behind
+			if after := runtime.NumGoroutine(); after > before {
+				t.Errorf("goroutines leaked: before %d, after %d", before, after)
+			}
+		})
+	}
+}
+
+func TestLeadingZeroChecks(t *testing.T) {
+	zeros5, zeros6 := LeadingZeroNibbles(5), LeadingZeroNibbles(6)
+	bits20 := LeadingZeroBits(20)
+	for i := uint(0); i < 2_000_000; i += 7 {
+		h := CalcHash("abcdef", i)
+		if zeros5(h[:]) != CheckPrefix5(h) || bits20(h[:]) != CheckPrefix5(h) {
+			t.Fatalf("suffix %d: 5 zeros differ from CheckPrefix5", i)
+		}
+		if zeros6(h[:]) != CheckPrefix6(h) {
+			t.Fatalf("suffix %d: 6 zeros differ from CheckPrefix6", i)
+		}
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	// md5("abcdef609043") = 000001dbbfa3a5c83a2d506429c7b00e
+	h := CalcHash("abcdef", 609043)
+
+	type predicateCase struct {
+		name  string
+		check Predicate
+		want  bool
+	}
+	testCases := []predicateCase{
+		{"0 zeros", LeadingZeroNibbles(0), true},
+		{"5 zeros", LeadingZeroNibbles(5), true},
+		{"6 zeros", LeadingZeroNibbles(6), false},
+		{"23 bits", LeadingZeroBits(23), true},
+		{"24 bits", LeadingZeroBits(24), false},
+		{"129 bits", LeadingZeroBits(129), false},
+		{"-1 bits", LeadingZeroBits(-1), true},
+		{"-9 bits", LeadingZeroBits(-9), true},
+	}
+	prefixCases := []struct {
+		prefix string
+		want   bool
+	}{
+		{"", true},
+		{"0", true},
+		{"000001d", true},
+		{"000001DBBF", true},
+		{"000001dbbfa3a5c83a2d506429c7b00e", true},
+		{"1", false},
+		{"000001c", false},
+		{"000001dbbfa3a5c83a2d506429c7b00e0", false},
+	}
+	for _, pc := range prefixCases {
+		check, err := HexPrefix(pc.prefix)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		testCases = append(testCases, predicateCase{"prefix " + pc.prefix, check, pc.want})
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.check(h[:]); got != tc.want {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := HexPrefix("abx"); err == nil {
+		t.Errorf("expected error for invalid hex prefix")
+	}
+}
+
+func TestMinerAlgorithms(t *testing.T) {
+	check := LeadingZeroBits(10)
+	for name, newHash := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			var want uint
+			for !check(CalcDigest(newHash, "abcdef", want)) {
+				want++
+			}
+			for _, workers := range []int{1, 3} {
+				m := Miner{Secret: "abcdef", Check: check, NewHash: newHash, Workers: workers}
+				got, err := m.Solve(context.Background())
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != want {
+					t.Errorf("%d workers want: %d, got: %d", workers, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHasherDigest(t *testing.T) {
+	for name, newHash := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			hs := newHasher(newHash, "abcdef")
+			for _, suffix := range []uint{0, 9, 10, 609043, math.MaxUint} {
+				want := CalcDigest(newHash, "abcdef", suffix)
+				if got := hs.digest(suffix); !bytes.Equal(got, want) {
+					t.Errorf("suffix %d want: %x, got: %x", suffix, want, got)
+				}
+			}
+		})
+	}
+
+	hs := newHasher(md5.New, "abcdef")
+	if hs.state == nil {
+		t.Fatalf("md5 state is not precomputed")
+	}
+	allocs := testing.AllocsPerRun(100, func() { hs.digest(609043) })
+	if allocs != 0 {
+		t.Errorf("digest allocates %v times per run", allocs)
+	}
+}
+
+func TestRangePoolComplete(t *testing.T) {
+	var searched atomic.Uint64
+	p := newRangePool(100, &searched)
+	steps := []struct {
+		from, to uint
+		want     uint
+	}{
+		{200, 300, 100},
+		{300, 400, 100},
+		{100, 200, 400},
+		{500, 600, 400},
+		{400, 500, 600},
+	}
+	for _, st := range steps {
+		p.complete(st.from, st.to)
+		if got := uint(searched.Load()); got != st.want {
+			t.Errorf("after [%d, %d) want: %d, got: %d", st.from, st.to, st.want, got)
+		}
+	}
+}
+
+func TestMinerStart(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		m := Miner{Secret: "abcdef", Check: LeadingZeroNibbles(5), Workers: workers, Start: 609044}
+		got, err := m.Solve(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got <= 609043 || !CheckPrefix5(CalcHash("abcdef", got)) {
+			t.Errorf("%d workers: %d is not a solution after the start", workers, got)
+		}
+	}
+}
+
+func TestMinerStartNearMaxUint(t *testing.T) {
+	start := uint(math.MaxUint - 10)
+	for _, workers := range []int{1, 4} {
+		m := Miner{Secret: "abcdef", Check: func([]byte) bool { return true }, Workers: workers, Start: start}
+		got, err := m.Solve(context.Background())
+		if err != nil || got != start {
+			t.Errorf("%d workers: want: %d, got: %d, %v", workers, start, got, err)
+		}
+
+		// the last suffixes are searched once without wrapping around to 0
+		m = Miner{Secret: "abcdef", Check: func([]byte) bool { return false }, Workers: workers, Start: start}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = m.Solve(ctx)
+		cancel()
+		if !errors.Is(err, errNotFound) {
+			t.Errorf("%d workers: want: %v, got: %v", workers, errNotFound, err)
+		}
+	}
+}
+
+func TestSolveWithCheckpoints(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp := Checkpoint{Secret: "abcdef", Algo: "md5", Predicate: "never"}
+	check := func([]byte) bool { return false }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	m := Miner{Secret: "abcdef", Check: check, Workers: 2}
+	_, err := SolveWithCheckpoints(ctx, &m, cp, name, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want: %v, got: %v", context.DeadlineExceeded, err)
+	}
+
+	saved, err := LoadCheckpoint(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !saved.SameSearch(&cp) {
+		t.Errorf("checkpoint of another search: %+v", saved)
+	}
+	if saved.Searched == 0 || saved.Searched != m.Searched() {
+		t.Errorf("final checkpoint want: %d, got: %d", m.Searched(), saved.Searched)
+	}
+
+	// resumed search does not go back
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	resumed := Miner{Secret: "abcdef", Check: check, Workers: 2, Start: saved.Searched}
+	SolveWithCheckpoints(ctx, &resumed, cp, name, time.Hour)
+	if resumed.Searched() <= saved.Searched {
+		t.Errorf("resumed search has not moved: %d", resumed.Searched())
+	}
+}
+
+func TestSolveWithCheckpointsSaveError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "missing", "checkpoint.json")
+	cp := Checkpoint{Secret: "abcdef", Algo: "md5", Predicate: "never"}
+
+	// a failed save stops the search instead of running without checkpoints
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	m := Miner{Secret: "abcdef", Check: func([]byte) bool { return false }, Workers: 2}
+	_, err := SolveWithCheckpoints(ctx, &m, cp, name, time.Millisecond)
+	if err == nil || ctx.Err() != nil || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want the save error, got: %v", err)
+	}
+
+	// a found suffix is returned even when the save has failed during the search
+	slow := func(digest []byte) bool {
+		time.Sleep(20 * time.Millisecond)
+		return true
+	}
+	m = Miner{Secret: "abcdef", Check: slow, Workers: 1}
+	s, err := SolveWithCheckpoints(ctx, &m, cp, name, time.Millisecond)
+	if err != nil || s != 0 {
+		t.Errorf("want: 0, got: %d, %v", s, err)
+	}
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err = SolveWithCheckpoints(ctx, &m, cp, name, interval); err == nil {
+			t.Errorf("interval %v: want error", interval)
+		}
+	}
+}
+
+func TestMinerProgress(t *testing.T) {
+	var mu sync.Mutex
+	reports := make([]Progress, 0)
+	m := Miner{
+		Secret:           "abcdef",
+		Check:            LeadingZeroNibbles(5),
+		Workers:          1,
+		ProgressInterval: 10 * time.Millisecond,
+		OnProgress: func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, p)
+		},
+	}
+	got, err := m.Solve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) == 0 {
+		t.Fatalf("no progress reports")
+	}
+	last := reports[len(reports)-1]
+	if last.Hashes != uint64(got)+1 || last.Hashes != m.Hashes() {
+		t.Errorf("final report want %d hashes, got: %d", got+1, last.Hashes)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].Hashes < reports[i-1].Hashes || reports[i].Searched < reports[i-1].Searched {
+			t.Errorf("progress goes back: %+v after %+v", reports[i], reports[i-1])
+		}
+	}
+}
+
+func TestProgressMetrics(t *testing.T) {
+	pm := &ProgressMetrics{}
+	pm.Update(Progress{Hashes: 1234, PerSecond: 100.5, Searched: 1000})
+
+	rec := httptest.NewRecorder()
+	pm.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"miner_hashes_total 1234\n", "miner_hashes_per_second 100.5\n", "miner_searched_suffix 1000\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics have no %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestListenLoopback(t *testing.T) {
+	for _, addr := range []string{":0", "0.0.0.0:0", "[::]:0", "example.com:0", "10.0.0.1:0", "localhost"} {
+		if ln, err := listenLoopback(addr); err == nil {
+			ln.Close()
+			t.Errorf("%s: want error", addr)
+		}
+	}
+	for _, addr := range []string{"localhost:0", "127.0.0.1:0"} {
+		ln, err := listenLoopback(addr)
+		if err != nil {
+			t.Errorf("%s: %v", addr, err)
+			continue
+		}
+		ln.Close()
+	}
+}
+
+func TestMinerAll(t *testing.T) {
+	check := LeadingZeroBits(8)
+	want := make([]uint, 0)
+	for i := uint(100); len(want) < 50; i++ {
+		if check(CalcDigest(md5.New, "abcdef", i)) {
+			want = append(want, i)
+		}
+	}
+
+	for _, workers := range []int{1, 3, 8} {
+		t.Run(fmt.Sprintf("workers-%d", workers), func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			m := Miner{Secret: "abcdef", Check: check, Workers: workers, Start: 100}
+
+			got := make([]uint, 0)
+			for h, err := range m.All(context.Background(), len(want), 0) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(h.Digest, CalcDigest(md5.New, "abcdef", h.Suffix)) {
+					t.Errorf("wrong digest of %d", h.Suffix)
+				}
+				got = append(got, h.Suffix)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("want: %v, got: %v", want, got)
+			}
+
+			// the suffix limit, the consumer stops early
+			end := want[10]
+			got = got[:0]
+			for h := range m.All(context.Background(), 0, end) {
+				got = append(got, h.Suffix)
+			}
+			if !slices.Equal(got, want[:10]) {
+				t.Errorf("till %d want: %v, got: %v", end, want[:10], got)
+			}
+			for range m.All(context.Background(), 0, 0) {
+				break
+			}
+
+			if after := runtime.NumGoroutine(); after > before {
+				t.Errorf("goroutines leaked: before %d, after %d", before, after)
+			}
+		})
+	}
+}
+
+func TestPrintHits(t *testing.T) {
+	m := Miner{Secret: "abcdef", Check: LeadingZeroNibbles(5), Start: 609000}
+	var buf bytes.Buffer
+	err := printHits(context.Background(), &buf, &m, "zeros=5", 1, 0, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "difficulty zeros=5:\n609043 000001dbbfa3a5c83a2d506429c7b00e\n"
+	if buf.String() != want {
+		t.Errorf("want: %q got: %q", want, buf.String())
+	}
+}
+
+func TestMinerAllCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	m := Miner{Secret: "abcdef", Check: func([]byte) bool { return false }, Workers: 2}
+	var lastErr error
+	for _, err := range m.All(ctx, 0, 0) {
+		lastErr = err
+	}
+	if !errors.Is(lastErr, context.DeadlineExceeded) {
+		t.Errorf("want: %v, got: %v", context.DeadlineExceeded, lastErr)
+	}
+}
+
+func TestParsePredicate(t *testing.T) {
+	for _, name := range []string{"", "zeros", "zeros=x", "bits=-1", "prefix=xyz", "ones=3"} {
+		if _, err := ParsePredicate(name); err == nil {
+			t.Errorf("%q: want error", name)
+		}
+	}
+	check, err := ParsePredicate("zeros=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := CalcHash("abcdef", 609043)
+	if !check(digest[:]) {
+		t.Errorf("zeros=5 does not pass %x", digest)
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	job := Job{Secret: "abcdef", Algo: "md5", Predicate: "zeros=5"}
+	want := uint(609043)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCoordinator(job, 0)
+	c.rangeSize = 1 << 16
+	c.linger = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	type result struct {
+		s   uint
+		err error
+	}
+	served := make(chan result, 1)
+	go func() {
+		s, err := c.Serve(ctx, ln)
+		served <- result{s, err}
+	}()
+
+	// a worker that dies with the first range, the range must be searched by others
+	lost, err := rpc.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claim ClaimReply
+	err = lost.Call("Coordinator.Claim", ClaimArgs{}, &claim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim.From != 0 || claim.To != c.rangeSize || claim.Job != job {
+		t.Fatalf("unexpected claim %+v", claim)
+	}
+	lost.Close()
+
+	var wg sync.WaitGroup
+	workerErrs := make([]error, 2)
+	for i := range workerErrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workerErrs[i] = RunWorker(ctx, "tcp", ln.Addr().String(), 2)
+		}()
+	}
+
+	r := <-served
+	wg.Wait()
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.s != want {
+		t.Errorf("want: %d, got: %d", want, r.s)
+	}
+	for i, err := range workerErrs {
+		if err != nil {
+			t.Errorf("worker %d: %v", i, err)
+		}
+	}
+}
+
+func TestCoordinatorReleaseLastRange(t *testing.T) {
+	c := NewCoordinator(Job{Secret: "abcdef", Algo: "md5", Predicate: "zeros=5"}, math.MaxUint-10)
+	c.rangeSize = 1 << 16
+	first := c.claim(1)
+	if first.From != math.MaxUint-10 || first.To != math.MaxUint {
+		t.Fatalf("unexpected claim %+v", first)
+	}
+	c.release(1)
+	again := c.claim(2)
+	if again.From != first.From || again.To != first.To {
+		t.Errorf("want: %+v, got: %+v", first, again)
+	}
+}
+
+func TestReadSecret(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "input.txt")
+	err := os.WriteFile(file, []byte("abcdef\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		secret, file, stdin string
+		want                string
+		wantErr             bool
+	}{
+		{secret: "abcdef", want: "abcdef"},
+		{file: file, want: "abcdef"},
+		{file: "-", stdin: " pqrstuv\r\n", want: "pqrstuv"},
+		{secret: "abcdef", file: file, wantErr: true},
+		{file: "-", stdin: "\n", wantErr: true},
+		{want: "bgvyzdsv"},
+	}
+	for _, tc := range testCases {
+		got, err := readSecret(tc.secret, tc.file, strings.NewReader(tc.stdin))
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("%+v: got %q, %v", tc, got, err)
+		}
+	}
+}
+
+func TestPrintResult(t *testing.T) {
+	r := Result{Secret: "abcdef", Difficulty: "zeros=5", Suffix: 609043, Digest: "000001dbbfa3a5c83a2d506429c7b00e", Elapsed: time.Second, Hashes: 609044}
+	var buf bytes.Buffer
+	err := printResult(&buf, r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"secret":"abcdef","difficulty":"zeros=5","suffix":609043,"digest":"000001dbbfa3a5c83a2d506429c7b00e","elapsed_ns":1000000000,"hashes":609044}` + "\n"
+	if buf.String() != want {
+		t.Errorf("want: %s got: %s", want, buf.String())
+	}
+}
+
+func BenchmarkCalcHash(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CalcHash("bgvyzdsv", uint(i))
+	}
+}
+
+func BenchmarkHasherDigest(b *testing.B) {
+	b.Run("state", func(b *testing.B) {
+		hs := newHasher(md5.New, "bgvyzdsv")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hs.digest(uint(i))
+		}
+	})
+	b.Run("reset", func(b *testing.B) {
+		hs := newHasher(md5.New, "bgvyzdsv")
+		hs.state = nil
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hs.digest(uint(i))
+		}
+	})
+}
 
 func BenchmarkSolve(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -13,4 +646,14 @@ func BenchmarkSolveWorkers(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		SolveWithWorkers("bgvyzdsv", CheckPrefix6)
 	}
-}
\ No newline at end of file
+}
+
+func BenchmarkSolveWorkerCount(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				SolveWithWorkerCount(context.Background(), "bgvyzdsv", CheckPrefix6, workers)
+			}
+		})
+	}
+}
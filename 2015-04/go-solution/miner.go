@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Predicate checks a digest of any hash algorithm
+type Predicate = func([]byte) bool
+
+// Algorithms are hash factories which can be selected by name
+var Algorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha1":    sha1.New,
+	"sha256":  sha256.New,
+	"sha512":  sha512.New,
+	"fnv32a":  func() hash.Hash { return fnv.New32a() },
+	"fnv64a":  func() hash.Hash { return fnv.New64a() },
+	"fnv128a": func() hash.Hash { return fnv.New128a() },
+	"crc32":   func() hash.Hash { return crc32.NewIEEE() },
+}
+
+// Md5Predicate adapts a check of Md5Hash to a Predicate. The digest must be an MD5 one.
+func Md5Predicate(hCheck func(Md5Hash) bool) Predicate {
+	return func(digest []byte) bool {
+		return hCheck(Md5Hash(digest))
+	}
+}
+
+// CalcDigest is CalcHash for any hash algorithm. Miner uses the allocation-free hasher instead.
+func CalcDigest(newHash func() hash.Hash, input string, suffix uint) []byte {
+	h := newHash()
+	fmt.Fprintf(h, "%s%d", input, suffix)
+	return h.Sum(nil)
+}
+
+// Miner searches the lowest suffix which hash together with the secret passes the check.
+// Check must not keep the digest, the slice is reused.
+type Miner struct {
+	Secret  string
+	Check   Predicate
+	NewHash func() hash.Hash // md5.New if nil
+	Workers int              // runtime.NumCPU() if 0, a single worker searches in the calling goroutine
+	Start   uint             // the first suffix to search, e.g. from a checkpoint
+
+	OnProgress       func(Progress) // called every ProgressInterval and once more when Solve returns
+	ProgressInterval time.Duration  // time.Second if 0
+
+	searched atomic.Uint64
+	hashed   atomic.Uint64
+}
+
+// Hashes returns the number of hashes tried by the running or the last Solve
+func (m *Miner) Hashes() uint64 {
+	return m.hashed.Load()
+}
+
+// Searched returns the suffix below which all suffixes from Start have been searched
+// by the running or the last Solve. It is safe to call it concurrently with Solve.
+func (m *Miner) Searched() uint {
+	return uint(m.searched.Load())
+}
+
+// Solve returns the lowest suffix starting from Start. It stops when ctx is done and returns ctx.Err().
+// All workers are finished when it returns.
+func (m *Miner) Solve(ctx context.Context) (uint, error) {
+	m.hashed.Store(0)
+	m.searched.Store(uint64(m.Start))
+	if m.OnProgress != nil {
+		stop := m.reportProgress()
+		defer stop()
+	}
+
+	workers := m.workerCount()
+	if workers == 1 {
+		return m.solveSingle(ctx)
+	}
+
+	pool := newRangePool(m.Start, &m.searched)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.work(ctx, m.searcher())
+		}()
+	}
+	wg.Wait()
+
+	// a canceled worker could leave a lower range unsearched
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if s, ok := pool.result(); ok {
+		return s, nil
+	}
+	return 0, errNotFound
+}
+
+func (m *Miner) solveSingle(ctx context.Context) (uint, error) {
+	search := m.searcher()
+	for from := m.Start; from < math.MaxUint; {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		to := rangeEnd(from)
+		if s, ok := search(from, to); ok {
+			return s, nil
+		}
+		m.searched.Store(uint64(to))
+		from = to
+	}
+	return 0, errNotFound
+}
+
+// searcher returns a function which finds the first passing suffix in [from, to).
+// Every worker needs its own searcher as it reuses the hash state and buffers.
+func (m *Miner) searcher() func(from, to uint) (uint, bool) {
+	hs := m.newHasher()
+	return func(from, to uint) (uint, bool) {
+		for i := from; i < to; i++ {
+			if m.Check(hs.digest(i)) {
+				m.hashed.Add(uint64(i - from + 1))
+				return i, true
+			}
+		}
+		m.hashed.Add(uint64(to - from))
+		return 0, false
+	}
+}
+
+func (m *Miner) newHasher() *hasher {
+	newHash := m.NewHash
+	if newHash == nil {
+		newHash = md5.New
+	}
+	return newHasher(newHash, m.Secret)
+}
+
+// workerCount returns the number of workers to start
+func (m *Miner) workerCount() int {
+	if m.Workers == 0 {
+		return runtime.NumCPU()
+	}
+	return m.Workers
+}
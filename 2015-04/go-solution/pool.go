@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// rangePool hands out consecutive suffix ranges to workers and keeps the lowest found suffix.
+// Ranges are claimed in ascending order, so when all workers are finished
+// every range below the lowest found suffix has been searched completely.
+type rangePool struct {
+	next atomic.Uint64 // start of the next unclaimed range
+	best atomic.Uint64 // the lowest found suffix, math.MaxUint if nothing is found
+
+	mu       sync.Mutex
+	searched *atomic.Uint64 // all suffixes below are searched
+	pending  map[uint]uint  // searched ranges above the searched suffix, from -> to
+}
+
+func newRangePool(start uint, searched *atomic.Uint64) *rangePool {
+	p := &rangePool{searched: searched, pending: make(map[uint]uint)}
+	p.next.Store(uint64(start))
+	p.best.Store(math.MaxUint)
+	searched.Store(uint64(start))
+	return p
+}
+
+// claim returns the next range to search. It returns false when the range cannot have
+// a lower suffix than the already found one or there are no more suffixes.
+// The last range ends at math.MaxUint, so the next one never wraps around to 0.
+func (p *rangePool) claim() (uint, uint, bool) {
+	for {
+		from := p.next.Load()
+		if from >= p.best.Load() || from >= math.MaxUint {
+			return 0, 0, false
+		}
+		to := rangeEnd(uint(from))
+		if p.next.CompareAndSwap(from, uint64(to)) {
+			return uint(from), to, true
+		}
+	}
+}
+
+// report keeps the suffix if it is lower than the found one
+func (p *rangePool) report(s uint) {
+	for {
+		best := p.best.Load()
+		if uint64(s) >= best || p.best.CompareAndSwap(best, uint64(s)) {
+			return
+		}
+	}
+}
+
+// complete marks the range as searched without a hit.
+// Ranges are completed out of order, the searched suffix moves only over a contiguous run of them.
+func (p *rangePool) complete(from, to uint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	searched := uint(p.searched.Load())
+	if from != searched {
+		p.pending[from] = to
+		return
+	}
+	searched = to
+	for {
+		next, ok := p.pending[searched]
+		if !ok {
+			break
+		}
+		delete(p.pending, searched)
+		searched = next
+	}
+	p.searched.Store(uint64(searched))
+}
+
+func (p *rangePool) result() (uint, bool) {
+	best := p.best.Load()
+	return uint(best), best != math.MaxUint
+}
+
+// work searches claimed ranges until the pool is exhausted, a suffix is found or ctx is done.
+// Ranges above the found suffix cannot improve the result, so the worker stops after a hit.
+func (p *rangePool) work(ctx context.Context, search func(from, to uint) (uint, bool)) {
+	for ctx.Err() == nil {
+		from, to, ok := p.claim()
+		if !ok {
+			return
+		}
+		if s, found := search(from, to); found {
+			p.report(s)
+			return
+		}
+		p.complete(from, to)
+	}
+}
+
+// rangeEnd returns the end of the batch started at from without overflowing uint
+func rangeEnd(from uint) uint {
+	if from > math.MaxUint-batchSize {
+		return math.MaxUint
+	}
+	return from + batchSize
+}
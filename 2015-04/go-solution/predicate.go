@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LeadingZeroNibbles returns a check that the first n hex digits of the digest are zeros
+func LeadingZeroNibbles(n int) Predicate {
+	return LeadingZeroBits(4 * n)
+}
+
+// LeadingZeroBits returns a check that the first n bits of the digest are zeros.
+// Nothing passes if n is bigger than the digest, everything passes if n is not positive.
+func LeadingZeroBits(n int) Predicate {
+	n = max(n, 0)
+	full, rest := n/8, n%8
+	mask := byte(0xFF << (8 - rest))
+	return func(digest []byte) bool {
+		return hasZeroBits(digest, full, mask)
+	}
+}
+
+// hasZeroBits checks that full bytes are zeros and the bits of mask are zeros in the next byte
+func hasZeroBits(b []byte, full int, mask byte) bool {
+	if full > len(b) || (full == len(b) && mask != 0) {
+		return false
+	}
+	for _, v := range b[:full] {
+		if v != 0 {
+			return false
+		}
+	}
+	return mask == 0 || b[full]&mask == 0
+}
+
+// HexPrefix returns a check that the digest in hex starts with the prefix, e.g. "abc".
+// Nothing passes if the prefix is longer than the digest.
+func HexPrefix(prefix string) (Predicate, error) {
+	prefix = strings.ToLower(prefix)
+	// an odd prefix is padded and the last nibble is masked
+	padded := prefix
+	if len(prefix)%2 == 1 {
+		padded += "0"
+	}
+	want, err := hex.DecodeString(padded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex prefix %q: %w", prefix, err)
+	}
+	lastMask := byte(0xFF)
+	if len(prefix)%2 == 1 {
+		lastMask = 0xF0
+	}
+	return func(digest []byte) bool {
+		return hasPrefixBits(digest, want, lastMask)
+	}, nil
+}
+
+// hasPrefixBits checks that b starts with want, the last byte of want is compared under lastMask
+func hasPrefixBits(b, want []byte, lastMask byte) bool {
+	if len(want) == 0 {
+		return true
+	}
+	if len(want) > len(b) {
+		return false
+	}
+	last := len(want) - 1
+	for i := 0; i < last; i++ {
+		if b[i] != want[i] {
+			return false
+		}
+	}
+	return b[last]&lastMask == want[last]
+}
+
+// ParsePredicate builds a predicate from its name: zeros=N, bits=N or prefix=HEX.
+// The names identify searches in checkpoints and jobs of distributed workers.
+func ParsePredicate(name string) (Predicate, error) {
+	kind, value, ok := strings.Cut(name, "=")
+	if !ok {
+		return nil, fmt.Errorf("invalid predicate %q", name)
+	}
+	switch kind {
+	case "zeros", "bits":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid predicate %q", name)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid predicate %q: %s cannot be negative", name, kind)
+		}
+		if kind == "zeros" {
+			return LeadingZeroNibbles(n), nil
+		}
+		return LeadingZeroBits(n), nil
+	case "prefix":
+		return HexPrefix(value)
+	}
+	return nil, fmt.Errorf("unknown predicate %q", name)
+}
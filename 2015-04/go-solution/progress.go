@@ -0,0 +1,118 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Progress is a report of a running search
+type Progress struct {
+	Hashes    uint64        `json:"hashes"`     // hashes tried since the start of Solve
+	PerSecond float64       `json:"per_second"` // hashes per second since the previous report
+	Searched  uint          `json:"searched"`   // all suffixes below are searched
+	Elapsed   time.Duration `json:"elapsed_ns"`
+}
+
+// reportProgress calls OnProgress every ProgressInterval until the returned stop is called.
+// stop makes the final report and waits for the reporting goroutine.
+func (m *Miner) reportProgress() (stop func()) {
+	interval := m.ProgressInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	start := time.Now()
+	last, lastTime := uint64(0), start
+	report := func(now time.Time) {
+		hashes := m.Hashes()
+		p := Progress{Hashes: hashes, Searched: m.Searched(), Elapsed: now.Sub(start)}
+		if d := now.Sub(lastTime).Seconds(); d > 0 {
+			p.PerSecond = float64(hashes-last) / d
+		}
+		last, lastTime = hashes, now
+		m.OnProgress(p)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				report(time.Now())
+				return
+			case now := <-ticker.C:
+				report(now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// ProgressLine writes the progress as a single terminal line, every report overwrites the previous one
+func ProgressLine(w io.Writer) func(Progress) {
+	return func(p Progress) {
+		fmt.Fprintf(w, "\r%d hashes, %.2f MH/s, searched below %d, %v   ",
+			p.Hashes, p.PerSecond/1e6, p.Searched, p.Elapsed.Round(time.Second))
+	}
+}
+
+// ProgressMetrics keeps the last progress and exposes it as metrics
+type ProgressMetrics struct {
+	mu   sync.Mutex
+	last Progress
+}
+
+// Update is an OnProgress callback
+func (pm *ProgressMetrics) Update(p Progress) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.last = p
+}
+
+func (pm *ProgressMetrics) Last() Progress {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return pm.last
+}
+
+// ServeHTTP writes the last progress in the Prometheus text format
+func (pm *ProgressMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := pm.Last()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP miner_hashes_total Hashes tried by the current search.\n")
+	fmt.Fprintf(w, "# TYPE miner_hashes_total counter\n")
+	fmt.Fprintf(w, "miner_hashes_total %d\n", p.Hashes)
+	fmt.Fprintf(w, "# HELP miner_hashes_per_second Hash rate since the previous report.\n")
+	fmt.Fprintf(w, "# TYPE miner_hashes_per_second gauge\n")
+	fmt.Fprintf(w, "miner_hashes_per_second %g\n", p.PerSecond)
+	fmt.Fprintf(w, "# HELP miner_searched_suffix All suffixes below are searched.\n")
+	fmt.Fprintf(w, "# TYPE miner_searched_suffix gauge\n")
+	fmt.Fprintf(w, "miner_searched_suffix %d\n", p.Searched)
+}
+
+// Handler serves /metrics in the Prometheus text format and /debug/vars with expvar
+func (pm *ProgressMetrics) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", pm)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Publish exposes the last progress as the expvar variable with the name
+func (pm *ProgressMetrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return pm.Last()
+	}))
+}
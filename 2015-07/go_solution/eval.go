@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/verybigtuple/advent/go2015-07/parser"
+)
+
+// minGatesPerWorker is the smallest part of a level that is worth a separate goroutine.
+// Gates are too cheap to split narrow levels between workers.
+const minGatesPerWorker = 256
+
+// gate is a statement with its wires resolved into indexes of Circuit values
+type gate struct {
+	line     *parser.ParsedLine
+	out      int
+	inA, inB int
+}
+
+// Circuit is a netlist sorted in topological order and split into levels.
+// Every gate of a level depends only on wires from the previous levels,
+// so gates of the same level can be calculated independently.
+type Circuit struct {
+	names  []string
+	index  map[string]int
+	levels [][]gate
+}
+
+// NewCircuit builds levels of the netlist. Wires which cannot be resolved
+// (unknown inputs or loops) are left out of the circuit, just like CalcWire cannot resolve them.
+func NewCircuit(wires []*parser.ParsedLine) (*Circuit, error) {
+	c := &Circuit{index: make(map[string]int, len(wires))}
+	for _, w := range wires {
+		if _, ok := c.index[w.IntoWire]; ok {
+			return nil, fmt.Errorf("wire %s has more than one source", w.IntoWire)
+		}
+		c.index[w.IntoWire] = len(c.names)
+		c.names = append(c.names, w.IntoWire)
+	}
+
+	// Kahn's algorithm: a gate is ready when all its distinct inputs are calculated
+	waiting := make([]int, len(wires))
+	dependents := make(map[string][]int)
+	current := make([]int, 0)
+	for i, w := range wires {
+		inputs := w.Inputs()
+		waiting[i] = len(inputs)
+		for _, in := range inputs {
+			dependents[in] = append(dependents[in], i)
+		}
+		if len(inputs) == 0 {
+			current = append(current, i)
+		}
+	}
+
+	for len(current) > 0 {
+		level := make([]gate, 0, len(current))
+		next := make([]int, 0)
+		for _, i := range current {
+			level = append(level, c.newGate(wires[i]))
+			for _, d := range dependents[wires[i].IntoWire] {
+				waiting[d]--
+				if waiting[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+		c.levels = append(c.levels, level)
+		current = next
+	}
+	return c, nil
+}
+
+func (c *Circuit) newGate(pl *parser.ParsedLine) gate {
+	g := gate{line: pl, out: c.index[pl.IntoWire], inA: -1, inB: -1}
+	switch s := pl.Statement.(type) {
+	case parser.WireInput:
+		g.inA = c.index[s.Input]
+	case parser.Unary:
+		g.inA = c.index[s.Input]
+	case parser.PureBinary:
+		g.inB = c.index[s.InputB]
+	case parser.WiredBinary:
+		g.inA, g.inB = c.index[s.InputA], c.index[s.InputB]
+	case parser.Shift:
+		g.inA = c.index[s.Input]
+	}
+	return g
+}
+
+// Levels returns the number of levels in the circuit
+func (c *Circuit) Levels() int {
+	return len(c.levels)
+}
+
+// Eval calculates all resolvable wires of the circuit in a single goroutine
+func (c *Circuit) Eval() (map[string]uint16, error) {
+	return c.EvalConcurrent(1)
+}
+
+// EvalConcurrent calculates all resolvable wires of the circuit level by level.
+// Every wide level is split between the given number of workers.
+// The result does not depend on the number of workers.
+func (c *Circuit) EvalConcurrent(workers int) (map[string]uint16, error) {
+	return c.EvalWith(nil, workers)
+}
+
+// EvalWith calculates the circuit like EvalConcurrent, but the given wires
+// get the override values instead of their own statements.
+func (c *Circuit) EvalWith(overrides map[string]uint16, workers int) (map[string]uint16, error) {
+	idxOverrides := make(map[int]uint16, len(overrides))
+	for name, value := range overrides {
+		i, ok := c.index[name]
+		if !ok {
+			return nil, fmt.Errorf("wire %s not found", name)
+		}
+		idxOverrides[i] = value
+	}
+
+	values, err := c.evalValues(idxOverrides, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	calculatedWires := make(map[string]uint16, len(c.names))
+	for _, level := range c.levels {
+		for _, g := range level {
+			calculatedWires[c.names[g.out]] = values[g.out]
+		}
+	}
+	return calculatedWires, nil
+}
+
+// evalValues calculates values of all wires indexed as Circuit names
+func (c *Circuit) evalValues(overrides map[int]uint16, workers int) ([]uint16, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	values := make([]uint16, len(c.names))
+	for _, level := range c.levels {
+		err := evalLevel(level, values, workers)
+		if err != nil {
+			return nil, err
+		}
+		if len(overrides) == 0 {
+			continue
+		}
+		for _, g := range level {
+			if v, ok := overrides[g.out]; ok {
+				values[g.out] = v
+			}
+		}
+	}
+	return values, nil
+}
+
+// EvalWire calculates the circuit and returns the value of a single wire
+func (c *Circuit) EvalWire(wireName string, workers int) (uint16, error) {
+	calculatedWires, err := c.EvalConcurrent(workers)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := calculatedWires[wireName]
+	if !ok {
+		return 0, fmt.Errorf("wire with the name %s cannot be resolved", wireName)
+	}
+	return value, nil
+}
+
+func evalLevel(level []gate, values []uint16, workers int) error {
+	chunks := min(workers, len(level)/minGatesPerWorker)
+	if chunks <= 1 {
+		return evalGates(level, values)
+	}
+
+	// every gate writes only its own wire, so chunks do not overlap
+	var wg sync.WaitGroup
+	errs := make([]error, chunks)
+	chunkSize := (len(level) + chunks - 1) / chunks
+	for i := 0; i < chunks; i++ {
+		from := i * chunkSize
+		to := min(from+chunkSize, len(level))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = evalGates(level[from:to], values)
+		}()
+	}
+	wg.Wait()
+
+	// take the error of the first chunk to keep the result deterministic
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func evalGates(gates []gate, values []uint16) error {
+	for _, g := range gates {
+		var err error
+		switch s := g.line.Statement.(type) {
+		case parser.PureInput:
+			values[g.out] = s.Input
+		case parser.WireInput:
+			values[g.out] = values[g.inA]
+		case parser.Unary:
+			values[g.out], err = CalcUnary(values[g.inA], s.Operand)
+		case parser.PureBinary:
+			values[g.out], err = CalcBinary(s.InputA, values[g.inB], s.Operand)
+		case parser.WiredBinary:
+			values[g.out], err = CalcBinary(values[g.inA], values[g.inB], s.Operand)
+		case parser.Shift:
+			values[g.out], err = CalcShift(values[g.inA], s.Param, s.Operand)
+		default:
+			err = errors.ErrUnsupported
+		}
+		if err != nil {
+			return fmt.Errorf("wire %s: %w", g.line.IntoWire, err)
+		}
+	}
+	return nil
+}
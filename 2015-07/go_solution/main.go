@@ -3,9 +3,12 @@ package main
 import (
 	"bufio"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/verybigtuple/advent/go2015-07/parser"
 )
@@ -118,36 +121,38 @@ func CalcWire(wires []*parser.ParsedLine, wireName string) (uint16, error) {
 	}
 }
 
-func readAllWires(f *os.File) ([]*parser.ParsedLine, error) {
-	p := parser.New(bufio.NewReader(f))
-	wires := make([]*parser.ParsedLine, 0)
-	for {
-		parsedLine, err := p.NextLine()
-		if err != nil {
-			if errors.Is(err, parser.ErrEOF) {
-				break
-			}
-			return nil, err
-		}
-		wires = append(wires, parsedLine)
+// calcWire calculates a wire either with CalcWire or with a Circuit evaluated by workers
+func calcWire(wires []*parser.ParsedLine, wireName string, workers int) (uint16, error) {
+	if workers == 0 {
+		return CalcWire(wires, wireName)
 	}
-	return wires, nil
+	c, err := NewCircuit(wires)
+	if err != nil {
+		return 0, err
+	}
+	return c.EvalWire(wireName, workers)
 }
 
-func run() error {
-	f, err := os.Open("input.txt")
+// readWiresFile reads the netlist with all included netlists or the binary netlist written by encode
+func readWiresFile(fileName string) ([]*parser.ParsedLine, error) {
+	data, err := os.ReadFile(fileName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
+	if parser.IsBinary(data) {
+		return parser.DecodeBytes(data)
+	}
+	return parser.Load(os.DirFS(filepath.Dir(fileName)), filepath.Base(fileName))
+}
 
-	wires, err := readAllWires(f)
+func run(fileName string, workers int) error {
+	wires, err := readWiresFile(fileName)
 	if err != nil {
 		return err
 	}
 
 	// calculate the firtst part of the problem
-	result1, err := CalcWire(wires, "a")
+	result1, err := calcWire(wires, "a", workers)
 	if err != nil {
 		return err
 	}
@@ -161,7 +166,7 @@ func run() error {
 	wires[bWireIdx].Statement = parser.PureInput{Input: result1}
 
 	// calculate the second part of the problem
-	result2, err := CalcWire(wires, "a")
+	result2, err := calcWire(wires, "a", workers)
 	if err != nil {
 		return err
 	}
@@ -171,8 +176,87 @@ func run() error {
 
 }
 
+// runSensitivity prints which input bits can influence every bit of the wire
+func runSensitivity(fileName, wireName string, workers int) error {
+	wires, err := readWiresFile(fileName)
+	if err != nil {
+		return err
+	}
+	c, err := NewCircuit(wires)
+	if err != nil {
+		return err
+	}
+	s, err := AnalyzeSensitivity(c, wireName, workers)
+	if err != nil {
+		return err
+	}
+	_, err = s.WriteTo(os.Stdout)
+	return err
+}
+
+// runEncode writes the netlist as a binary file
+func runEncode(fileName, outName string) error {
+	wires, err := readWiresFile(fileName)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(outName)
+	if err != nil {
+		return err
+	}
+	err = parser.Encode(out, wires)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// runDecode prints the netlist as text
+func runDecode(fileName string) error {
+	wires, err := readWiresFile(fileName)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(os.Stdout)
+	for _, wire := range wires {
+		fmt.Fprintln(w, wire)
+	}
+	return w.Flush()
+}
+
+// runCommand runs a subcommand. Without a subcommand the problem is solved.
+func runCommand(args []string) error {
+	cmd := "solve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fileName := fs.String("input", "input.txt", "file with the netlist")
+	workers := fs.Int("workers", 0, "number of workers evaluating the circuit level by level, 0 to use CalcWire")
+
+	switch cmd {
+	case "solve":
+		fs.Parse(args)
+		return run(*fileName, *workers)
+	case "sensitivity":
+		wireName := fs.String("wire", "a", "output wire to analyze")
+		fs.Parse(args)
+		return runSensitivity(*fileName, *wireName, max(*workers, 1))
+	case "encode":
+		outName := fs.String("output", "input.bin", "file for the binary netlist")
+		fs.Parse(args)
+		return runEncode(*fileName, *outName)
+	case "decode":
+		fs.Parse(args)
+		return runDecode(*fileName)
+	}
+	return fmt.Errorf("unknown command %s", cmd)
+}
+
 func main() {
-	err := run()
+	err := runCommand(os.Args[1:])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
@@ -1,33 +1,40 @@
 package main
 
 import (
+	"fmt"
+	"maps"
 	"testing"
 
 	"github.com/verybigtuple/advent/go2015-07/parser"
 )
 
-func TestComplexShema(t *testing.T) {
-	testCases := []struct {
-		parsedLine *parser.ParsedLine
-		want       uint16
-	}{
-		{&parser.ParsedLine{"x", parser.PureInput{123}}, 123},
-		{&parser.ParsedLine{"y", parser.PureInput{456}}, 456},
-		{&parser.ParsedLine{"d", parser.WiredBinary{parser.And, "x", "y"}}, 72},
-		{&parser.ParsedLine{"e", parser.WiredBinary{parser.Or, "x", "y"}}, 507},
-		{&parser.ParsedLine{"f", parser.Shift{parser.LShift, "x", 2}}, 492},
-		{&parser.ParsedLine{"g", parser.Shift{parser.RShift, "y", 2}}, 114},
-		{&parser.ParsedLine{"h", parser.Unary{parser.Not, "x"}}, 65412},
-		{&parser.ParsedLine{"i", parser.Unary{parser.Not, "y"}}, 65079},
-		{&parser.ParsedLine{"j", parser.WireInput{"x"}}, 123},
-	}
-
-	wires := make([]*parser.ParsedLine, 0, len(testCases))
-	for _, tc := range testCases {
+// complexShema is the schema of the puzzle example with the values of its wires
+var complexShema = []struct {
+	parsedLine *parser.ParsedLine
+	want       uint16
+}{
+	{&parser.ParsedLine{IntoWire: "x", Statement: parser.PureInput{Input: 123}}, 123},
+	{&parser.ParsedLine{IntoWire: "y", Statement: parser.PureInput{Input: 456}}, 456},
+	{&parser.ParsedLine{IntoWire: "d", Statement: parser.WiredBinary{Operand: parser.And, InputA: "x", InputB: "y"}}, 72},
+	{&parser.ParsedLine{IntoWire: "e", Statement: parser.WiredBinary{Operand: parser.Or, InputA: "x", InputB: "y"}}, 507},
+	{&parser.ParsedLine{IntoWire: "f", Statement: parser.Shift{Operand: parser.LShift, Input: "x", Param: 2}}, 492},
+	{&parser.ParsedLine{IntoWire: "g", Statement: parser.Shift{Operand: parser.RShift, Input: "y", Param: 2}}, 114},
+	{&parser.ParsedLine{IntoWire: "h", Statement: parser.Unary{Operand: parser.Not, Input: "x"}}, 65412},
+	{&parser.ParsedLine{IntoWire: "i", Statement: parser.Unary{Operand: parser.Not, Input: "y"}}, 65079},
+	{&parser.ParsedLine{IntoWire: "j", Statement: parser.WireInput{Input: "x"}}, 123},
+}
+
+func complexShemaWires() []*parser.ParsedLine {
+	wires := make([]*parser.ParsedLine, 0, len(complexShema))
+	for _, tc := range complexShema {
 		wires = append(wires, tc.parsedLine)
 	}
+	return wires
+}
 
-	for _, tc := range testCases {
+func TestComplexShema(t *testing.T) {
+	wires := complexShemaWires()
+	for _, tc := range complexShema {
 		t.Run(tc.parsedLine.IntoWire, func(t *testing.T) {
 			got, err := CalcWire(wires, tc.parsedLine.IntoWire)
 			if err != nil {
@@ -41,3 +48,169 @@ func TestComplexShema(t *testing.T) {
 	}
 
 }
+
+func TestCircuitEval(t *testing.T) {
+	c, err := NewCircuit(complexShemaWires())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := c.Eval()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range complexShema {
+		if got[tc.parsedLine.IntoWire] != tc.want {
+			t.Errorf("wire %s want: %d, got: %d", tc.parsedLine.IntoWire, tc.want, got[tc.parsedLine.IntoWire])
+		}
+	}
+
+	_, err = c.EvalWire("z", 1)
+	if err == nil {
+		t.Errorf("expected error for unresolvable wire")
+	}
+}
+
+func TestCircuitEvalConcurrent(t *testing.T) {
+	c, err := NewCircuit(wideNetlist(2000, 10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, err := c.Eval()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, workers := range []int{2, 3, 8} {
+		got, err := c.EvalConcurrent(workers)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !maps.Equal(got, want) {
+			t.Errorf("workers %d: result differs from sequential evaluation", workers)
+		}
+	}
+}
+
+func TestAnalyzeSensitivity(t *testing.T) {
+	wires := []*parser.ParsedLine{
+		{IntoWire: "x", Statement: parser.PureInput{Input: 0b1010}},
+		{IntoWire: "y", Statement: parser.PureInput{Input: 0b0110}},
+		{IntoWire: "d", Statement: parser.WiredBinary{Operand: parser.And, InputA: "x", InputB: "y"}},
+		{IntoWire: "f", Statement: parser.Shift{Operand: parser.LShift, Input: "x", Param: 2}},
+		{IntoWire: "k", Statement: parser.PureBinary{Operand: parser.And, InputA: 1, InputB: "y"}},
+	}
+	c, err := NewCircuit(wires)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		wire       string
+		bit        int
+		structural map[string]uint16
+		observed   map[string]uint16
+	}{
+		{"d", 1, map[string]uint16{"x": 0b10, "y": 0b10}, map[string]uint16{"x": 0b10, "y": 0b10}},
+		{"d", 2, map[string]uint16{"x": 0b100, "y": 0b100}, map[string]uint16{"x": 0b100}},
+		{"d", 3, map[string]uint16{"x": 0b1000, "y": 0b1000}, map[string]uint16{"y": 0b1000}},
+		{"f", 0, map[string]uint16{}, map[string]uint16{}},
+		{"f", 3, map[string]uint16{"x": 0b10}, map[string]uint16{"x": 0b10}},
+		{"k", 0, map[string]uint16{"y": 0b1}, map[string]uint16{"y": 0b1}},
+		{"k", 1, map[string]uint16{}, map[string]uint16{}},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s[%d]", tc.wire, tc.bit), func(t *testing.T) {
+			s, err := AnalyzeSensitivity(c, tc.wire, 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !maps.Equal(s.Structural[tc.bit], tc.structural) {
+				t.Errorf("structural want: %v, got: %v", tc.structural, s.Structural[tc.bit])
+			}
+			if !maps.Equal(s.Observed[tc.bit], tc.observed) {
+				t.Errorf("observed want: %v, got: %v", tc.observed, s.Observed[tc.bit])
+			}
+		})
+	}
+}
+
+func TestFormatMask(t *testing.T) {
+	testCases := []struct {
+		mask uint16
+		want string
+	}{
+		{0, ""},
+		{0b1, "0"},
+		{0b1000_1111, "0-3,7"},
+		{0xFFFF, "0-15"},
+		{0b1010_0000_0000_0000, "13,15"},
+	}
+	for _, tc := range testCases {
+		if got := formatMask(tc.mask); got != tc.want {
+			t.Errorf("mask %b want: %q, got: %q", tc.mask, tc.want, got)
+		}
+	}
+}
+
+// wireName converts a number into a wire name: 0 -> a, 25 -> z, 26 -> ba, ...
+func wireName(i int) string {
+	name := []byte{byte('a' + i%26)}
+	for i /= 26; i > 0; i /= 26 {
+		name = append([]byte{byte('a' + i%26)}, name...)
+	}
+	return string(name)
+}
+
+// wideNetlist generates a netlist of the given number of levels where every level has width gates
+func wideNetlist(width, depth int) []*parser.ParsedLine {
+	wires := make([]*parser.ParsedLine, 0, width*depth)
+	for j := 0; j < width; j++ {
+		wires = append(wires, &parser.ParsedLine{IntoWire: wireName(j), Statement: parser.PureInput{Input: uint16(j * 7919)}})
+	}
+	for d := 1; d < depth; d++ {
+		for j := 0; j < width; j++ {
+			a := wireName((d-1)*width + j)
+			b := wireName((d-1)*width + (j+1)%width)
+			var s interface{}
+			switch j % 5 {
+			case 0:
+				s = parser.WiredBinary{Operand: parser.And, InputA: a, InputB: b}
+			case 1:
+				s = parser.WiredBinary{Operand: parser.Or, InputA: a, InputB: b}
+			case 2:
+				s = parser.Shift{Operand: parser.LShift, Input: a, Param: byte(j % 16)}
+			case 3:
+				s = parser.Unary{Operand: parser.Not, Input: a}
+			default:
+				s = parser.PureBinary{Operand: parser.And, InputA: uint16(j), InputB: b}
+			}
+			wires = append(wires, &parser.ParsedLine{IntoWire: wireName(d*width + j), Statement: s})
+		}
+	}
+	return wires
+}
+
+func BenchmarkCircuitEval(b *testing.B) {
+	wires := wideNetlist(20000, 50)
+	last := wires[len(wires)-1].IntoWire
+	c, err := NewCircuit(wires)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("CalcWire", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CalcWire(wires, last)
+		}
+	})
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.Eval()
+		}
+	})
+	for _, workers := range []int{2, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c.EvalConcurrent(workers)
+			}
+		})
+	}
+}
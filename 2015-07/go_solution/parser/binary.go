@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Binary netlist layout, all integers are little endian:
+//
+//	magic    [4]byte "W07N"
+//	version  uint16
+//	names    uvarint count, then every name as uvarint length and bytes
+//	lines    uvarint count, then every line as opcode byte and its operands
+//	checksum uint32 CRC-32 (IEEE) of everything above
+//
+// Wires are uvarint indexes in names, numbers are uint16 and shift params are a single byte.
+const (
+	binaryMagic   = "W07N"
+	BinaryVersion = 1
+)
+
+// ErrInvalidBinary is wrapped by all errors of decoding a malformed binary netlist
+var ErrInvalidBinary = errors.New("invalid binary netlist")
+
+type opcode = byte
+
+const (
+	opPureInput opcode = iota + 1 // out, value
+	opWireInput                   // out, in
+	opNot                         // out, in
+	opPureAnd                     // out, value, in
+	opPureOr                      // out, value, in
+	opAnd                         // out, a, b
+	opOr                          // out, a, b
+	opLShift                      // out, in, param
+	opRShift                      // out, in, param
+)
+
+// IsBinary checks if the data starts as a binary netlist
+func IsBinary(head []byte) bool {
+	return bytes.HasPrefix(head, []byte(binaryMagic))
+}
+
+// Encode writes parsed lines as a binary netlist. Include directives must be expanded before.
+func Encode(w io.Writer, lines []*ParsedLine) error {
+	names := make([]string, 0)
+	index := make(map[string]uint64)
+	wire := func(name string) uint64 {
+		i, ok := index[name]
+		if !ok {
+			i = uint64(len(names))
+			index[name] = i
+			names = append(names, name)
+		}
+		return i
+	}
+
+	body := make([]byte, 0, len(lines)*8)
+	for _, pl := range lines {
+		out := wire(pl.IntoWire)
+		switch s := pl.Statement.(type) {
+		case PureInput:
+			body = append(body, opPureInput)
+			body = binary.AppendUvarint(body, out)
+			body = binary.LittleEndian.AppendUint16(body, s.Input)
+		case WireInput:
+			body = append(body, opWireInput)
+			body = binary.AppendUvarint(body, out)
+			body = binary.AppendUvarint(body, wire(s.Input))
+		case Unary:
+			if s.Operand != Not {
+				return fmt.Errorf("wire %s: unsupported operand %s", pl.IntoWire, s.Operand)
+			}
+			body = append(body, opNot)
+			body = binary.AppendUvarint(body, out)
+			body = binary.AppendUvarint(body, wire(s.Input))
+		case PureBinary:
+			op, err := binaryOpcode(s.Operand, opPureAnd, opPureOr)
+			if err != nil {
+				return fmt.Errorf("wire %s: %w", pl.IntoWire, err)
+			}
+			body = append(body, op)
+			body = binary.AppendUvarint(body, out)
+			body = binary.LittleEndian.AppendUint16(body, s.InputA)
+			body = binary.AppendUvarint(body, wire(s.InputB))
+		case WiredBinary:
+			op, err := binaryOpcode(s.Operand, opAnd, opOr)
+			if err != nil {
+				return fmt.Errorf("wire %s: %w", pl.IntoWire, err)
+			}
+			body = append(body, op)
+			body = binary.AppendUvarint(body, out)
+			body = binary.AppendUvarint(body, wire(s.InputA))
+			body = binary.AppendUvarint(body, wire(s.InputB))
+		case Shift:
+			op := opLShift
+			switch s.Operand {
+			case LShift:
+			case RShift:
+				op = opRShift
+			default:
+				return fmt.Errorf("wire %s: unsupported operand %s", pl.IntoWire, s.Operand)
+			}
+			body = append(body, op)
+			body = binary.AppendUvarint(body, out)
+			body = binary.AppendUvarint(body, wire(s.Input))
+			body = append(body, s.Param)
+		default:
+			return fmt.Errorf("wire %s: cannot encode statement %T", pl.IntoWire, s)
+		}
+	}
+
+	buf := make([]byte, 0, len(body)+len(names)*4+32)
+	buf = append(buf, binaryMagic...)
+	buf = binary.LittleEndian.AppendUint16(buf, BinaryVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(names)))
+	for _, name := range names {
+		buf = binary.AppendUvarint(buf, uint64(len(name)))
+		buf = append(buf, name...)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(lines)))
+	buf = append(buf, body...)
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func binaryOpcode(operand BinaryOperand, and, or opcode) (opcode, error) {
+	switch operand {
+	case And:
+		return and, nil
+	case Or:
+		return or, nil
+	}
+	return 0, fmt.Errorf("unsupported operand %s", operand)
+}
+
+// Decode reads a binary netlist written by Encode
+func Decode(r io.Reader) ([]*ParsedLine, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeBytes(data)
+}
+
+// DecodeBytes decodes a binary netlist already loaded into memory.
+// Lines with the same wire share a single string.
+func DecodeBytes(data []byte) ([]*ParsedLine, error) {
+	if !IsBinary(data) {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidBinary)
+	}
+	if len(data) < len(binaryMagic)+2+4 {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidBinary)
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidBinary)
+	}
+	version := binary.LittleEndian.Uint16(body[len(binaryMagic):])
+	if version != BinaryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBinary, version)
+	}
+
+	d := decoder{buf: body[len(binaryMagic)+2:]}
+	nameCount := d.uvarint()
+	if nameCount > uint64(len(d.buf)) {
+		return nil, fmt.Errorf("%w: bad name count", ErrInvalidBinary)
+	}
+	names := make([]string, nameCount)
+	for i := range names {
+		names[i] = string(d.bytes(d.uvarint()))
+	}
+	wire := func() string {
+		i := d.uvarint()
+		if i >= uint64(len(names)) {
+			if d.err == nil {
+				d.err = fmt.Errorf("%w: wire index %d out of range", ErrInvalidBinary, i)
+			}
+			return ""
+		}
+		return names[i]
+	}
+
+	lineCount := d.uvarint()
+	if lineCount > uint64(len(d.buf)) {
+		return nil, fmt.Errorf("%w: bad line count", ErrInvalidBinary)
+	}
+	lines := make([]*ParsedLine, 0, lineCount)
+	for i := uint64(0); i < lineCount && d.err == nil; i++ {
+		op := d.uint8()
+		pl := &ParsedLine{IntoWire: wire()}
+		switch op {
+		case opPureInput:
+			pl.Statement = PureInput{d.uint16()}
+		case opWireInput:
+			pl.Statement = WireInput{wire()}
+		case opNot:
+			pl.Statement = Unary{Not, wire()}
+		case opPureAnd, opPureOr:
+			operand := And
+			if op == opPureOr {
+				operand = Or
+			}
+			value := d.uint16()
+			pl.Statement = PureBinary{operand, value, wire()}
+		case opAnd, opOr:
+			operand := And
+			if op == opOr {
+				operand = Or
+			}
+			a := wire()
+			pl.Statement = WiredBinary{operand, a, wire()}
+		case opLShift, opRShift:
+			operand := LShift
+			if op == opRShift {
+				operand = RShift
+			}
+			in := wire()
+			pl.Statement = Shift{operand, in, d.uint8()}
+		default:
+			if d.err == nil {
+				d.err = fmt.Errorf("%w: unknown opcode %d", ErrInvalidBinary, op)
+			}
+		}
+		lines = append(lines, pl)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.buf) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidBinary, len(d.buf))
+	}
+	return lines, nil
+}
+
+// decoder reads values from the buffer and remembers the first error
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: unexpected end of data", ErrInvalidBinary)
+	}
+	d.buf = nil
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if n > uint64(len(d.buf)) {
+		d.fail()
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) uint8() byte {
+	b := d.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	b := d.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+)
+
+// Load reads the netlist from the file name of fsys and expands all include directives.
+//
+// The wires of an included netlist are prefixed with its alias, so the wire sum of
+// `include "adder.txt" as add1` becomes add1.sum and the netlist can be included several times.
+// The parent binds the wires with ordinary statements: `x -> add1.a` drives the input a
+// which is left without a source in adder.txt, and `add1.sum -> s` reads its output.
+// Include paths are relative to the directory of the including file and cannot leave fsys.
+func Load(fsys fs.FS, name string) ([]*ParsedLine, error) {
+	return load(fsys, name, "", nil)
+}
+
+func load(fsys fs.FS, name, prefix string, stack []string) ([]*ParsedLine, error) {
+	if slices.Contains(stack, name) {
+		return nil, fmt.Errorf("include cycle: %s includes itself", name)
+	}
+	stack = append(stack, name)
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	wires := make([]*ParsedLine, 0)
+	for parsedLine, err := range All(f) {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		inc, ok := parsedLine.Statement.(Include)
+		if !ok {
+			wires = append(wires, parsedLine.Prefixed(prefix))
+			continue
+		}
+		incWires, err := load(fsys, path.Join(path.Dir(name), inc.Path), prefixWire(prefix, inc.Alias), stack)
+		if err != nil {
+			return nil, err
+		}
+		wires = append(wires, incWires...)
+	}
+	return wires, nil
+}
+
+// Prefixed returns a copy of the line with all wire names prefixed as wires of an included netlist
+func (pl *ParsedLine) Prefixed(prefix string) *ParsedLine {
+	if prefix == "" {
+		return pl
+	}
+	p := func(wire string) string { return prefixWire(prefix, wire) }
+
+	prefixed := ParsedLine{IntoWire: p(pl.IntoWire), Statement: pl.Statement}
+	switch s := pl.Statement.(type) {
+	case WireInput:
+		prefixed.Statement = WireInput{p(s.Input)}
+	case Unary:
+		prefixed.Statement = Unary{s.Operand, p(s.Input)}
+	case PureBinary:
+		prefixed.Statement = PureBinary{s.Operand, s.InputA, p(s.InputB)}
+	case WiredBinary:
+		prefixed.Statement = WiredBinary{s.Operand, p(s.InputA), p(s.InputB)}
+	case Shift:
+		prefixed.Statement = Shift{s.Operand, p(s.Input), s.Param}
+	}
+	return &prefixed
+}
+
+func prefixWire(prefix, wire string) string {
+	if prefix == "" {
+		return wire
+	}
+	return prefix + "." + wire
+}
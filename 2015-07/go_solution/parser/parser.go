@@ -4,12 +4,57 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"strconv"
+	"strings"
+	"unicode"
 )
 
 type ParsedLine struct {
 	IntoWire  string
-	Statement interface{} // PureInput, WireInput, Unary, WiredBinary, PureBinary, Shift
+	Statement interface{} // PureInput, WireInput, Unary, WiredBinary, PureBinary, Shift, Include
+}
+
+// String formats the line back into the netlist syntax
+func (pl *ParsedLine) String() string {
+	switch s := pl.Statement.(type) {
+	case PureInput:
+		return fmt.Sprintf("%d %s %s", s.Input, arrow, pl.IntoWire)
+	case WireInput:
+		return fmt.Sprintf("%s %s %s", s.Input, arrow, pl.IntoWire)
+	case Unary:
+		return fmt.Sprintf("%s %s %s %s", s.Operand, s.Input, arrow, pl.IntoWire)
+	case PureBinary:
+		return fmt.Sprintf("%d %s %s %s %s", s.InputA, s.Operand, s.InputB, arrow, pl.IntoWire)
+	case WiredBinary:
+		return fmt.Sprintf("%s %s %s %s %s", s.InputA, s.Operand, s.InputB, arrow, pl.IntoWire)
+	case Shift:
+		return fmt.Sprintf("%s %s %d %s %s", s.Input, s.Operand, s.Param, arrow, pl.IntoWire)
+	case Include:
+		return fmt.Sprintf("%s %q %s %s", include, s.Path, as, s.Alias)
+	}
+	return fmt.Sprintf("%v %s %s", pl.Statement, arrow, pl.IntoWire)
+}
+
+// Inputs returns distinct names of the wires the statement reads from
+func (pl *ParsedLine) Inputs() []string {
+	switch s := pl.Statement.(type) {
+	case WireInput:
+		return []string{s.Input}
+	case Unary:
+		return []string{s.Input}
+	case PureBinary:
+		return []string{s.InputB}
+	case WiredBinary:
+		if s.InputA == s.InputB {
+			return []string{s.InputA}
+		}
+		return []string{s.InputA, s.InputB}
+	case Shift:
+		return []string{s.Input}
+	}
+	return nil
 }
 
 
@@ -22,26 +67,76 @@ type Parser struct {
 
 func New(src *bufio.Reader) *Parser {
 	scanner := bufio.NewScanner(src)
-	scanner.Split(bufio.ScanWords)
+	scanner.Split(scanTokens)
 	return &Parser{
 		scanner: scanner,
 		line:    0,
 	}
 }
 
+// scanTokens splits the input into words like bufio.ScanWords, but a quoted string
+// is a single token with all its spaces: "my lib/adder.txt"
+func scanTokens(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) && unicode.IsSpace(rune(data[start])) {
+		start++
+	}
+	if start == len(data) || data[start] != '"' {
+		return bufio.ScanWords(data, atEOF)
+	}
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, data[start : i+1], nil
+		}
+	}
+	if atEOF {
+		// an unterminated string is left for the parser to report
+		return len(data), data[start:], nil
+	}
+	return start, nil, nil
+}
+
+// All returns an iterator over the statements of r.
+// The iteration ends at the end of r or after the first error is yielded.
+func All(r io.Reader) iter.Seq2[*ParsedLine, error] {
+	return func(yield func(*ParsedLine, error) bool) {
+		p := New(bufio.NewReader(r))
+		for {
+			parsedLine, err := p.NextLine()
+			if errors.Is(err, ErrEOF) {
+				return
+			}
+			if !yield(parsedLine, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// NextLine parses the next statement. It returns ErrEOF when the input is over.
 func (p *Parser) NextLine() (*ParsedLine, error) {
 	// we have to read 2 tokens in order to determine the possible type of the statement
 	var err error
 	p.bufTokens[0], err = p.readNextSrc()
-	if errors.Is(err, ErrEOF) {
+	if err != nil {
 		return nil, err
 	}
 	p.bufTokens[1], err = p.readNextSrc()
 	if errors.Is(err, ErrEOF) {
-		return nil, &ParsingError{p.line, "unexpected end of file", err}
+		return nil, &ParsingError{p.line, "unexpected end of file", io.ErrUnexpectedEOF}
+	}
+	if err != nil {
+		return nil, err
 	}
 	defer func() { p.line += 1 }()
 
+	if p.bufTokens[0] == include && strings.HasPrefix(p.bufTokens[1], `"`) {
+		return p.parseAsInclude()
+	}
+
 	if p.bufTokens[0] == Not {
 		return p.parseAsUnary()
 	}
@@ -68,6 +163,9 @@ func (p *Parser) readNextSrc() (string, error) {
 	if p.scanner.Scan() {
 		return p.scanner.Text(), nil
 	}
+	if err := p.scanner.Err(); err != nil {
+		return "", err
+	}
 	return "", ErrEOF
 }
 
@@ -83,12 +181,12 @@ func (p *Parser) getNextToken() (string, error) {
 
 func (p *Parser) expectArrowScan() (string, error) {
 	arrowToken, err := p.getNextToken()
+	if errors.Is(err, ErrEOF) {
+		return "", &ParsingError{p.line, "expected -> but got EOF", io.ErrUnexpectedEOF}
+	}
 	if err != nil {
 		return "", err
 	}
-	if errors.Is(err, ErrEOF) {
-		return "", &ParsingError{p.line, "expected -> but got EOF", ErrEOF}
-	}
 	if arrowToken == arrow {
 		return arrowToken, nil
 	}
@@ -98,7 +196,7 @@ func (p *Parser) expectArrowScan() (string, error) {
 func (p *Parser) expectIntScan() (uint16, error) {
 	token, err := p.getNextToken()
 	if errors.Is(err, ErrEOF) {
-		return 0, &ParsingError{p.line, "expected integer but got EOF", ErrEOF}
+		return 0, &ParsingError{p.line, "expected integer but got EOF", io.ErrUnexpectedEOF}
 	}
 	input, err := strconv.ParseInt(token, 10, 16)
 	if err != nil {
@@ -110,14 +208,52 @@ func (p *Parser) expectIntScan() (uint16, error) {
 func (p *Parser) expectAlphaScan() (string, error) {
 	token, err := p.getNextToken()
 	if errors.Is(err, ErrEOF) {
-		return "", &ParsingError{p.line, "expected non-numeric token but got EOF", ErrEOF}
+		return "", &ParsingError{p.line, "expected non-numeric token but got EOF", io.ErrUnexpectedEOF}
 	}
-	if !isAlpha(token) {
-		return "", &ParsingError{p.line, fmt.Sprintf("expected alpha token but got %s", token), nil}
+	if !isWireName(token) {
+		return "", &ParsingError{p.line, fmt.Sprintf("expected wire name but got %s", token), nil}
 	}
 	return token, nil
 }
 
+func (p *Parser) parseAsInclude() (*ParsedLine, error) {
+	// include "adder.txt" as add1
+	_, err := p.getNextToken() // consume include as we have already checked it
+	if err != nil {
+		return nil, err
+	}
+
+	pathToken, err := p.getNextToken() // "adder.txt"
+	if err != nil {
+		return nil, err
+	}
+	path, err := strconv.Unquote(pathToken)
+	if err != nil || path == "" {
+		return nil, &ParsingError{p.line, fmt.Sprintf("expected quoted file name but got %s", pathToken), nil}
+	}
+
+	asToken, err := p.getNextToken() // as
+	if errors.Is(err, ErrEOF) {
+		return nil, &ParsingError{p.line, "expected as but got EOF", io.ErrUnexpectedEOF}
+	}
+	if asToken != as {
+		return nil, &ParsingError{p.line, fmt.Sprintf("expected as, Got %s", asToken), nil}
+	}
+
+	alias, err := p.expectAlphaScan() // add1
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(alias, ".") {
+		return nil, &ParsingError{p.line, fmt.Sprintf("alias %s cannot contain dots", alias), nil}
+	}
+
+	parsedLine := ParsedLine{
+		Statement: Include{Path: path, Alias: alias},
+	}
+	return &parsedLine, nil
+}
+
 func (p *Parser) parseAsUnary() (*ParsedLine, error) {
 	// NOT x -> h
 	_, err := p.getNextToken() // consume NOT as we have already checked it
@@ -318,12 +454,18 @@ func isNumeric(s string) bool {
 	return true
 }
 
-// isAlpha checks if a string is alphabetic
-func isAlpha(s string) bool {
-	for _, c := range s {
-		if c < 'a' || c > 'z' {
+// isWireName checks if a string is a wire name: lowercase letters and digits starting with a letter.
+// Wires of included netlists are prefixed with aliases separated by dots, like add1.sum
+func isWireName(s string) bool {
+	for _, seg := range strings.Split(s, ".") {
+		if seg == "" || seg[0] < 'a' || seg[0] > 'z' {
 			return false
 		}
+		for _, c := range seg {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+				return false
+			}
+		}
 	}
 	return true
 }
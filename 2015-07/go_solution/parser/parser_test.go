@@ -2,8 +2,15 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestParser(t *testing.T) {
@@ -21,6 +28,11 @@ func TestParser(t *testing.T) {
 		{"y RSHIFT 2 -> g", &ParsedLine{IntoWire: "g", Statement: Shift{Operand: RShift, Input: "y", Param: 2}}},
 		{"NOT x -> h", &ParsedLine{IntoWire: "h", Statement: Unary{Operand: Not, Input: "x"}}},
 		{"NOT y -> i", &ParsedLine{IntoWire: "i", Statement: Unary{Operand: Not, Input: "y"}}},
+		{`include "lib/adder.txt" as add1`, &ParsedLine{Statement: Include{Path: "lib/adder.txt", Alias: "add1"}}},
+		{`include "my lib/full adder.txt" as add1`, &ParsedLine{Statement: Include{Path: "my lib/full adder.txt", Alias: "add1"}}},
+		{`include "tab\there.txt" as add1`, &ParsedLine{Statement: Include{Path: "tab\there.txt", Alias: "add1"}}},
+		{"add1.sum -> s", &ParsedLine{IntoWire: "s", Statement: WireInput{Input: "add1.sum"}}},
+		{"x -> add1.a", &ParsedLine{IntoWire: "add1.a", Statement: WireInput{Input: "x"}}},
 	}
 
 	for _, tc := range testCases {
@@ -36,3 +48,246 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.txt": {Data: []byte(`1 -> x
+2 -> y
+include "lib/adder.txt" as add1
+include "lib/adder.txt" as add2
+x -> add1.a
+y -> add1.b
+add1.sum -> add2.a
+y -> add2.b
+add2.sum -> s`)},
+		"lib/adder.txt": {Data: []byte(`include "half.txt" as half
+a -> half.a
+b -> half.b
+half.or -> sum`)},
+		"lib/half.txt": {Data: []byte(`a OR b -> or`)},
+	}
+
+	got, err := Load(fsys, "main.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []ParsedLine{
+		{IntoWire: "x", Statement: PureInput{Input: 1}},
+		{IntoWire: "y", Statement: PureInput{Input: 2}},
+		{IntoWire: "add1.half.or", Statement: WiredBinary{Operand: Or, InputA: "add1.half.a", InputB: "add1.half.b"}},
+		{IntoWire: "add1.half.a", Statement: WireInput{Input: "add1.a"}},
+		{IntoWire: "add1.half.b", Statement: WireInput{Input: "add1.b"}},
+		{IntoWire: "add1.sum", Statement: WireInput{Input: "add1.half.or"}},
+		{IntoWire: "add2.half.or", Statement: WiredBinary{Operand: Or, InputA: "add2.half.a", InputB: "add2.half.b"}},
+		{IntoWire: "add2.half.a", Statement: WireInput{Input: "add2.a"}},
+		{IntoWire: "add2.half.b", Statement: WireInput{Input: "add2.b"}},
+		{IntoWire: "add2.sum", Statement: WireInput{Input: "add2.half.or"}},
+		{IntoWire: "add1.a", Statement: WireInput{Input: "x"}},
+		{IntoWire: "add1.b", Statement: WireInput{Input: "y"}},
+		{IntoWire: "add2.a", Statement: WireInput{Input: "add1.sum"}},
+		{IntoWire: "add2.b", Statement: WireInput{Input: "y"}},
+		{IntoWire: "s", Statement: WireInput{Input: "add2.sum"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("line %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadCycle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte(`include "b.txt" as b`)},
+		"b.txt": {Data: []byte(`include "a.txt" as a`)},
+	}
+	_, err := Load(fsys, "a.txt")
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestIsWireName(t *testing.T) {
+	valid := []string{"a", "lx", "add1", "add1.sum", "add1.half2.or"}
+	invalid := []string{"", "1a", "A", "add1.", ".a", "a..b", "a-b", `"a"`}
+	for _, s := range valid {
+		if !isWireName(s) {
+			t.Errorf("%q should be a wire name", s)
+		}
+	}
+	for _, s := range invalid {
+		if isWireName(s) {
+			t.Errorf("%q should not be a wire name", s)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	src := "123 -> x\nx AND y -> d\nNOT x -> h\n"
+
+	got := make([]string, 0)
+	for parsedLine, err := range All(strings.NewReader(src)) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, parsedLine.IntoWire)
+	}
+	if want := []string{"x", "d", "h"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// stop early
+	count := 0
+	for range All(strings.NewReader(src)) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("iteration has not stopped, got %d lines", count)
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	testCases := []struct {
+		input   string
+		wantEOF bool
+	}{
+		{"123 -> x\nx AND", true},
+		{"123 -> x\nx ->", true},
+		{"123 -> x\nx XOR y -> z", false},
+		{"123 -> x\ninclude \"a b.txt as a", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var lastErr error
+			count := 0
+			for _, err := range All(strings.NewReader(tc.input)) {
+				count++
+				lastErr = err
+			}
+			if count != 2 {
+				t.Errorf("got %d items, want 2", count)
+			}
+			var pErr *ParsingError
+			if !errors.As(lastErr, &pErr) || pErr.Line != 1 {
+				t.Fatalf("expected parsing error at line 1, got %v", lastErr)
+			}
+			if errors.Is(lastErr, io.EOF) {
+				t.Errorf("parsing error must not be io.EOF")
+			}
+			if got := errors.Is(lastErr, io.ErrUnexpectedEOF); got != tc.wantEOF {
+				t.Errorf("unexpected EOF: got %v, want %v", got, tc.wantEOF)
+			}
+		})
+	}
+}
+
+func TestErrEOF(t *testing.T) {
+	p := New(bufio.NewReader(strings.NewReader("")))
+	_, err := p.NextLine()
+	if !errors.Is(err, ErrEOF) || !errors.Is(err, io.EOF) {
+		t.Errorf("expected ErrEOF wrapping io.EOF, got %v", err)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	src := `123 -> x
+456 -> y
+x AND y -> d
+x OR y -> e
+1 AND y -> k
+3 OR y -> m
+x LSHIFT 2 -> f
+y RSHIFT 2 -> g
+NOT x -> h
+add1.sum -> s
+d -> x2`
+	want := make([]*ParsedLine, 0)
+	for parsedLine, err := range All(strings.NewReader(src)) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want = append(want, parsedLine)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, want); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if !IsBinary(buf.Bytes()) {
+		t.Errorf("encoded data has no magic")
+	}
+	got, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != *want[i] {
+			t.Errorf("line %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBinaryInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	lines := []*ParsedLine{{IntoWire: "x", Statement: PureInput{Input: 1}}}
+	if err := Encode(&buf, lines); err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	valid := buf.Bytes()
+
+	corrupted := slices.Clone(valid)
+	corrupted[len(corrupted)-5] ^= 0xFF
+
+	// the checksum is valid, so the decoder gets to the version
+	version := slices.Clone(valid)
+	version[4] = 99
+	binary.LittleEndian.PutUint32(version[len(version)-4:], crc32.ChecksumIEEE(version[:len(version)-4]))
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		wantMsg string
+	}{
+		{"empty", nil, "bad magic"},
+		{"magic", []byte("text -> x"), "bad magic"},
+		{"truncated", valid[:len(valid)-2], "checksum mismatch"},
+		{"checksum", corrupted, "checksum mismatch"},
+		{"version", version, "unsupported version 99"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeBytes(tc.data)
+			if !errors.Is(err, ErrInvalidBinary) {
+				t.Errorf("expected ErrInvalidBinary, got %v", err)
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantMsg) {
+				t.Errorf("expected error with %q, got %v", tc.wantMsg, err)
+			}
+		})
+	}
+
+	err := Encode(&buf, []*ParsedLine{{Statement: Include{Path: "a.txt", Alias: "a"}}})
+	if err == nil {
+		t.Errorf("expected error for include directive")
+	}
+}
+
+func TestParsedLineString(t *testing.T) {
+	for _, line := range []string{"123 -> x", "x AND y -> d", "1 OR y -> d", "x LSHIFT 2 -> f", "NOT x -> h", `include "a.txt" as a`} {
+		p := New(bufio.NewReader(strings.NewReader(line)))
+		parsedLine, err := p.NextLine()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := parsedLine.String(); got != line {
+			t.Errorf("got %q, want %q", got, line)
+		}
+	}
+}
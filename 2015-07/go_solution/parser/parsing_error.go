@@ -1,11 +1,14 @@
 package parser
 
 import (
-	"errors"
 	"fmt"
+	"io"
 )
 
-var ErrEOF error = errors.New("EOF")
+// ErrEOF is returned by NextLine when there are no more statements.
+// It wraps io.EOF, so errors.Is(err, io.EOF) is true for it.
+// A statement cut by the end of the input is a ParsingError wrapping io.ErrUnexpectedEOF.
+var ErrEOF error = fmt.Errorf("parser: %w", io.EOF)
 
 type ParsingError struct {
 	Line    int
@@ -6,6 +6,11 @@ type ShiftOperand = string
 
 const arrow string = "->"
 
+const (
+	include string = "include"
+	as      string = "as"
+)
+
 const (
 	And    BinaryOperand = "AND"
 	Or     BinaryOperand = "OR"
@@ -45,3 +50,10 @@ type Shift struct {
 	Input   string
 	Param   byte
 }
+
+// Include is a directive to instantiate the netlist of another file.
+// It is not a gate and has no IntoWire.
+type Include struct {
+	Path  string
+	Alias string
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/verybigtuple/advent/go2015-07/parser"
+)
+
+const wireBits = 16
+
+// BitDeps keeps for every bit of a wire the input wires it depends on
+// and a mask of the bits of every such input wire.
+// Input wires are the wires which get a signal directly from a number.
+type BitDeps [wireBits]map[string]uint16
+
+func newBitDeps() BitDeps {
+	var d BitDeps
+	for i := range d {
+		d[i] = make(map[string]uint16)
+	}
+	return d
+}
+
+// add adds the dependencies of the bit from into the bit to
+func (d *BitDeps) add(to int, src *BitDeps, from int) {
+	for wire, mask := range src[from] {
+		d[to][wire] |= mask
+	}
+}
+
+// Sensitivity is a report which input bits can influence bits of a wire
+type Sensitivity struct {
+	Wire string
+	// Structural are the input bits connected to the wire through the gates.
+	// The bits masked by constants in AND, OR and shifts are excluded.
+	Structural BitDeps
+	// Observed are the input bits which change the wire when flipped one by one
+	// with the rest of the inputs as they are in the netlist.
+	Observed BitDeps
+}
+
+// AnalyzeSensitivity builds the structural cone of the wire and checks every input bit of the cone
+// by simulation.
+func AnalyzeSensitivity(c *Circuit, wireName string, workers int) (*Sensitivity, error) {
+	out, ok := c.index[wireName]
+	if !ok {
+		return nil, fmt.Errorf("wire %s not found", wireName)
+	}
+
+	deps, err := c.structuralDeps()
+	if err != nil {
+		return nil, err
+	}
+	if deps[out] == nil {
+		return nil, fmt.Errorf("wire with the name %s cannot be resolved", wireName)
+	}
+
+	s := &Sensitivity{Wire: wireName, Structural: *deps[out], Observed: newBitDeps()}
+
+	baseValues, err := c.evalValues(nil, workers)
+	if err != nil {
+		return nil, err
+	}
+	base := baseValues[out]
+
+	// inputs of the whole cone, every bit is flipped separately
+	cone := make(map[string]uint16)
+	for bit := range s.Structural {
+		for wire, mask := range s.Structural[bit] {
+			cone[wire] |= mask
+		}
+	}
+	for _, wire := range slices.Sorted(maps.Keys(cone)) {
+		in := c.index[wire]
+		for inBit := 0; inBit < wireBits; inBit++ {
+			if cone[wire]&(1<<inBit) == 0 {
+				continue
+			}
+			values, err := c.evalValues(map[int]uint16{in: baseValues[in] ^ (1 << inBit)}, workers)
+			if err != nil {
+				return nil, err
+			}
+			changed := values[out] ^ base
+			for bit := 0; bit < wireBits; bit++ {
+				if changed&(1<<bit) != 0 {
+					s.Observed[bit][wire] |= 1 << inBit
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// structuralDeps calculates dependencies of every resolvable wire of the circuit.
+// Unresolvable wires have nil dependencies.
+func (c *Circuit) structuralDeps() ([]*BitDeps, error) {
+	deps := make([]*BitDeps, len(c.names))
+	for _, level := range c.levels {
+		for _, g := range level {
+			d := newBitDeps()
+			switch s := g.line.Statement.(type) {
+			case parser.PureInput:
+				for bit := range d {
+					d[bit][g.line.IntoWire] = 1 << bit
+				}
+			case parser.WireInput, parser.Unary:
+				for bit := range d {
+					d.add(bit, deps[g.inA], bit)
+				}
+			case parser.PureBinary:
+				for bit := range d {
+					constBit := s.InputA&(1<<bit) != 0
+					// 0 AND x and 1 OR x do not depend on x
+					if (s.Operand == parser.And && !constBit) || (s.Operand == parser.Or && constBit) {
+						continue
+					}
+					d.add(bit, deps[g.inB], bit)
+				}
+			case parser.WiredBinary:
+				for bit := range d {
+					d.add(bit, deps[g.inA], bit)
+					d.add(bit, deps[g.inB], bit)
+				}
+			case parser.Shift:
+				for bit := range d {
+					from := bit - int(s.Param)
+					if s.Operand == parser.RShift {
+						from = bit + int(s.Param)
+					}
+					if from >= 0 && from < wireBits {
+						d.add(bit, deps[g.inA], from)
+					}
+				}
+			default:
+				return nil, fmt.Errorf("wire %s: unsupported statement %T", g.line.IntoWire, s)
+			}
+			deps[g.out] = &d
+		}
+	}
+	return deps, nil
+}
+
+// WriteTo writes the report, one line for every bit of the wire from the most significant one
+func (s *Sensitivity) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	for bit := wireBits - 1; bit >= 0; bit-- {
+		fmt.Fprintf(&sb, "%s[%d]: structural %s; observed %s\n",
+			s.Wire, bit, formatDeps(s.Structural[bit]), formatDeps(s.Observed[bit]))
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// formatDeps formats dependencies as "b[0-3,7] c[15]"
+func formatDeps(deps map[string]uint16) string {
+	if len(deps) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(deps))
+	for _, wire := range slices.Sorted(maps.Keys(deps)) {
+		parts = append(parts, fmt.Sprintf("%s[%s]", wire, formatMask(deps[wire])))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatMask formats set bits as ranges: 0b1000_1111 -> "0-3,7"
+func formatMask(mask uint16) string {
+	ranges := make([]string, 0)
+	for bit := 0; bit < wireBits; bit++ {
+		if mask&(1<<bit) == 0 {
+			continue
+		}
+		last := bit
+		for last+1 < wireBits && mask&(1<<(last+1)) != 0 {
+			last++
+		}
+		if last == bit {
+			ranges = append(ranges, fmt.Sprint(bit))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", bit, last))
+		}
+		bit = last
+	}
+	return strings.Join(ranges, ",")
+}